@@ -0,0 +1,278 @@
+package main
+
+import (
+	"dyml-support"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// usage describes all subcommands.
+const usage = `Usage: dyml <command> [arguments]
+
+Without a command the DYML language server is started, communicating over stdin and stdout.
+Options like --stdio, which language clients pass, are ignored.
+
+Commands:
+  infer-schema [-o file] <dir>   infer a schema from all .dyml files in dir
+  gen-go [-o file] [-package name] [-type name] <file>...
+                                 generate Go types from a schema or sample documents
+  decode-xml [-o file] [-g2] [-root name] [file]
+                                 convert XML from file or stdin to DYML
+  import [-o dir] [-g2] [-root name] [-attr-prefix p] [-text-key k] [-scalars element|attribute]
+         [-arrays repeat|wrap] [-item name] <file>...
+                                 convert .xml, .json, .yaml and .yml files to .dyml files
+`
+
+// runCommand runs a subcommand and returns the exit code. Other flags than those for help are options of
+// the language server, like --stdio which language clients pass, so ok is false for them.
+func runCommand(args []string) (code int, ok bool) {
+	switch args[0] {
+	case "infer-schema":
+		return inferSchema(args[1:]), true
+	case "gen-go":
+		return genGo(args[1:]), true
+	case "decode-xml":
+		return decodeXML(args[1:]), true
+	case "import":
+		return importFiles(args[1:]), true
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+
+		return 0, true
+	}
+
+	if strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+
+	return 2, true
+}
+
+// inferSchema infers a schema from a directory and writes it to stdout or a file.
+func inferSchema(args []string) int {
+	flags := flag.NewFlagSet("infer-schema", flag.ContinueOnError)
+	output := flags.String("o", "", "write the schema to `file` instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+
+		return 2
+	}
+
+	schema, errs := dyml.InferSchemaDir(flags.Arg(0))
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "skipped:", err)
+	}
+
+	return writeOutput(*output, schema.Format())
+}
+
+// genGo generates Go types for a schema or sample documents and writes them to stdout or a file.
+func genGo(args []string) int {
+	flags := flag.NewFlagSet("gen-go", flag.ContinueOnError)
+	output := flags.String("o", "", "write the code to `file` instead of stdout")
+	pkg := flags.String("package", "", "`name` of the package, defaults to the package in the output directory")
+	typeName := flags.String("type", "", "`name` of the type for the whole document, defaults to the name of the first file")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+
+		return 2
+	}
+
+	src, err := dyml.GenerateGoFiles(flags.Args(), *output, *pkg, *typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return writeOutput(*output, src)
+}
+
+// decodeXML converts an XML file or stdin to DYML and writes it to stdout or a file.
+func decodeXML(args []string) int {
+	flags := flag.NewFlagSet("decode-xml", flag.ContinueOnError)
+	output := flags.String("o", "", "write the DYML to `file` instead of stdout")
+	g2 := flags.Bool("g2", false, "write G2 instead of G1")
+	rootName := flags.String("root", "root", "`name` of the root element, which is left out")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprint(os.Stderr, usage)
+
+		return 2
+	}
+
+	var (
+		src []byte
+		err error
+	)
+
+	if flags.NArg() == 1 {
+		src, err = os.ReadFile(flags.Arg(0))
+	} else {
+		src, err = io.ReadAll(os.Stdin)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	grammar := dyml.GrammarG1
+	if *g2 {
+		grammar = dyml.GrammarG2
+	}
+
+	converted, warnings, err := dyml.ConvertXML(string(src), grammar, *rootName)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return writeOutput(*output, converted)
+}
+
+// importFiles converts XML, JSON and YAML files to DYML files with the same name, next to them or in a directory.
+// Files with errors are reported and skipped.
+func importFiles(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	output := flags.String("o", "", "write the DYML files to `dir` instead of next to the converted files")
+	g2 := flags.Bool("g2", false, "write G2 instead of G1")
+
+	var options dyml.ImportOptions
+
+	flags.StringVar(&options.RootName, "root", "", "`name` of the root element of XML, which is left out (default \"root\")")
+	flags.StringVar(&options.AttributePrefix, "attr-prefix", "", "`prefix` of keys that are attributes (default \"@\")")
+	flags.StringVar(&options.TextKey, "text-key", "", "`key` of text (default \"#text\")")
+	flags.StringVar(&options.Scalars, "scalars", "", "map other scalars to `element` (default) or attribute")
+	flags.StringVar(&options.Arrays, "arrays", "", "map arrays to `repeat`ed elements (default) or wrap their items in an element")
+	flags.StringVar(&options.ItemName, "item", "", "`name` of elements for items of arrays in arrays (default \"item\")")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+
+		return 2
+	}
+
+	if *g2 {
+		options.Grammar = dyml.GrammarG2
+	}
+
+	formats := map[string]string{".xml": dyml.FormatXML, ".json": dyml.FormatJSON, ".yaml": dyml.FormatYAML, ".yml": dyml.FormatYAML}
+	exitCode := 0
+	// written remembers which file each DYML file was converted from, so that files like a.json and a.yaml
+	// do not overwrite each other.
+	written := map[string]string{}
+
+	for _, file := range flags.Args() {
+		ext := filepath.Ext(file)
+
+		format, ok := formats[strings.ToLower(ext)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: unknown format, expected .xml, .json, .yaml or .yml\n", file)
+
+			exitCode = 1
+
+			continue
+		}
+
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			exitCode = 1
+
+			continue
+		}
+
+		var (
+			converted string
+			warnings  []error
+		)
+
+		if format == dyml.FormatXML {
+			converted, warnings, err = dyml.ConvertXML(string(src), options.Grammar, options.RootName)
+		} else {
+			converted, err = dyml.ConvertObjects(string(src), format, options)
+		}
+
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", file, warning)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+
+			exitCode = 1
+
+			continue
+		}
+
+		target := strings.TrimSuffix(file, ext) + ".dyml"
+		if *output != "" {
+			target = filepath.Join(*output, filepath.Base(target))
+		}
+
+		if other, ok := written[target]; ok {
+			fmt.Fprintf(os.Stderr, "%s: skipped, %s was already converted from %s\n", file, target, other)
+
+			exitCode = 1
+
+			continue
+		}
+
+		written[target] = file
+
+		if code := writeOutput(target, converted); code != 0 {
+			exitCode = code
+		}
+	}
+
+	return exitCode
+}
+
+// writeOutput writes content to the file or to stdout if file is empty.
+func writeOutput(file, content string) int {
+	if file == "" {
+		fmt.Print(content)
+
+		return 0
+	}
+
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
@@ -10,19 +10,46 @@ import (
 )
 
 func main() {
+	// With a command we are used as a command line tool, otherwise as a language server.
+	if len(os.Args) > 1 {
+		if code, ok := runCommand(os.Args[1:]); ok {
+			os.Exit(code)
+		}
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	server := dyml.NewServer()
 
+	// Requests are read in the background, so that we can run the server's events in between.
+	requests := make(chan map[string]json.RawMessage)
+	go func() {
+		for {
+			request, err := readRequest(reader)
+			if err != nil {
+				log.Println("Error while reading request:", err)
+				continue
+			}
+			requests <- request
+		}
+	}()
+
 	// Continuously read and respond to requests
 	for {
-		request, err := readRequest(reader)
-		if err != nil {
-			log.Println("Error while reading request:", err)
+		var request map[string]json.RawMessage
+		select {
+		case request = <-requests:
+		case event := <-server.Events():
+			event()
 			continue
 		}
 
 		methodNameRaw, ok := request["method"]
 		if !ok {
+			if _, ok := request["id"]; ok {
+				// This is the client's response to one of our requests, which we do not wait for.
+				continue
+			}
+
 			log.Println("Got request with no method name!")
 			continue
 		}
@@ -46,7 +73,7 @@ func main() {
 		// Call the correct method on the server.
 		switch methodName {
 		case "initialize":
-			var params protocol.InitializeParams
+			var params protocol.InitializeParams317
 			if err := json.Unmarshal(request["params"], &params); err != nil {
 				log.Println(err)
 				continue
@@ -54,6 +81,34 @@ func main() {
 			sendResponse(server.Initialize(&params), requestId)
 		case "initialized":
 			server.Initialized()
+		case "workspace/didChangeConfiguration":
+			var params protocol.DidChangeConfigurationParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			server.DidChangeConfiguration(&params)
+		case "workspace/didChangeWatchedFiles":
+			var params protocol.DidChangeWatchedFilesParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			server.DidChangeWatchedFiles(&params)
+		case "workspace/symbol":
+			var params protocol.WorkspaceSymbolParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.WorkspaceSymbol(&params), requestId)
+		case "workspace/executeCommand":
+			var params protocol.ExecuteCommandParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.ExecuteCommand(&params), requestId)
 		case "$/cancelRequest":
 			// Cancelling a request only makes sense for a multithreaded server.
 		case "textDocument/hover":
@@ -63,6 +118,76 @@ func main() {
 				continue
 			}
 			sendResponse(server.Hover(&params), requestId)
+		case "textDocument/definition":
+			var params protocol.DefinitionParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.Definition(&params), requestId)
+		case "textDocument/documentLink":
+			var params protocol.DocumentLinkParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.DocumentLink(&params), requestId)
+		case "textDocument/references":
+			var params protocol.ReferenceParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.References(&params), requestId)
+		case "textDocument/documentHighlight":
+			var params protocol.DocumentHighlightParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.DocumentHighlight(&params), requestId)
+		case "textDocument/prepareRename":
+			var params protocol.PrepareRenameParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.PrepareRename(&params), requestId)
+		case "textDocument/rename":
+			var params dyml.RenameParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.Rename(&params), requestId)
+		case "textDocument/completion":
+			var params protocol.CompletionParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.Completion(&params), requestId)
+		case "textDocument/codeAction":
+			var params protocol.CodeActionParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.CodeAction(&params), requestId)
+		case "textDocument/diagnostic":
+			var params protocol.DocumentDiagnosticParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.DocumentDiagnostic(&params), requestId)
+		case "workspace/diagnostic":
+			var params protocol.WorkspaceDiagnosticParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.WorkspaceDiagnostic(&params), requestId)
 		case "textDocument/didSave":
 			var params protocol.DidSaveTextDocumentParams
 			if err := json.Unmarshal(request["params"], &params); err != nil {
@@ -99,13 +224,66 @@ func main() {
 			}
 			sendResponse(server.FullSemanticTokens(&params), requestId)
 		case "custom/encodeXML":
-			log.Println(string(request["params"]))
-			var params []protocol.DocumentURI
+			var params dyml.EncodeXMLParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				// Older clients send the URI of the document as the only parameter.
+				var uris []protocol.DocumentURI
+				if err := json.Unmarshal(request["params"], &uris); err != nil || len(uris) == 0 {
+					log.Println(err)
+					continue
+				}
+				params.TextDocument.URI = uris[0]
+			}
+			sendResponse(server.EncodeXML(&params), requestId)
+		case "custom/encode":
+			var params dyml.EncodeParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.Encode(&params), requestId)
+		case "custom/decodeXML":
+			var params dyml.DecodeXMLParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.DecodeXML(&params), requestId)
+		case "custom/decode":
+			var params dyml.DecodeParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.Decode(&params), requestId)
+		case "custom/xmlToSource":
+			var params dyml.XMLToSourceParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.XMLToSource(&params), requestId)
+		case "custom/sourceToXML":
+			var params dyml.SourceToXMLParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.SourceToXML(&params), requestId)
+		case "custom/preview/subscribe":
+			var params dyml.PreviewSubscribeParams
+			if err := json.Unmarshal(request["params"], &params); err != nil {
+				log.Println(err)
+				continue
+			}
+			sendResponse(server.PreviewSubscribe(&params), requestId)
+		case "custom/preview/unsubscribe":
+			var params dyml.PreviewUnsubscribeParams
 			if err := json.Unmarshal(request["params"], &params); err != nil {
 				log.Println(err)
 				continue
 			}
-			sendResponse(server.EncodeXML(params[0]), requestId)
+			sendResponse(server.PreviewUnsubscribe(&params), requestId)
 		default:
 			log.Printf("Unknown method '%s'\n", methodName)
 		}
@@ -0,0 +1,127 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"encoding/json"
+	"strings"
+)
+
+// Problem is a diagnostic together with the fixes we can offer for it.
+type Problem struct {
+	Diagnostic protocol.Diagnostic
+	Fixes      []Fix
+}
+
+// Fix is a quick fix that resolves a Problem by editing the document.
+type Fix struct {
+	Title string
+	Edits []protocol.TextEdit
+}
+
+// diagnosticsOf returns the diagnostics of all problems.
+func diagnosticsOf(problems []Problem) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	for _, problem := range problems {
+		diagnostics = append(diagnostics, problem.Diagnostic)
+	}
+
+	return diagnostics
+}
+
+// problems finds all problems in a document besides syntax errors, with the fixes we know for them.
+// Problems that are suppressed by directives in the document are left out.
+func (s *Server) problems(doc *Document) []Problem {
+	if doc.Err != nil {
+		return nil
+	}
+
+	var problems []Problem
+
+	problems = append(problems, s.schemaProblems(doc)...)
+	problems = append(problems, s.goTypeProblems(doc)...)
+	problems = append(problems, s.crossRefProblems(doc)...)
+	problems = append(problems, s.includeProblems(doc)...)
+	problems = append(problems, s.linkProblems(doc)...)
+	problems = append(problems, Lint(doc, s.config.Lint)...)
+
+	return suppress(doc, problems, s.config.Lint)
+}
+
+// CodeAction offers quick fixes for the diagnostics the client sent us, including syntax errors, and
+// the generation of Go types for the whole document.
+func (s *Server) CodeAction(params *protocol.CodeActionParams) []protocol.CodeAction {
+	actions := []protocol.CodeAction{}
+
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return actions
+	}
+
+	doc := ParseDocument(file)
+
+	if doc.Err == nil && wantsKind(params.Context.Only, protocol.Source) {
+		uri, _ := json.Marshal(file.Uri)
+		actions = append(actions, protocol.CodeAction{
+			Title: "Generate Go types",
+			Kind:  protocol.Source,
+			Command: &protocol.Command{
+				Title:     "Generate Go types",
+				Command:   CommandGenerateGo,
+				Arguments: []json.RawMessage{uri},
+			},
+		})
+	}
+
+	if !wantsKind(params.Context.Only, protocol.QuickFix) {
+		return actions
+	}
+
+	for _, problem := range append(syntaxProblems(doc), s.problems(doc)...) {
+		if len(problem.Fixes) == 0 || !containsDiagnostic(params.Context.Diagnostics, problem.Diagnostic) {
+			continue
+		}
+
+		for _, fix := range problem.Fixes {
+			actions = append(actions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix,
+				Diagnostics: []protocol.Diagnostic{problem.Diagnostic},
+				IsPreferred: len(problem.Fixes) == 1,
+				Edit: protocol.WorkspaceEdit{
+					Changes: map[string][]protocol.TextEdit{
+						string(file.Uri): fix.Edits,
+					},
+				},
+			})
+		}
+	}
+
+	return actions
+}
+
+// wantsKind returns true if the client asked for code actions of the kind, which it does
+// if it did not restrict the kinds or listed the kind or a parent of it.
+func wantsKind(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+
+	for _, k := range only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsDiagnostic returns true if the list contains a diagnostic with the same range and message.
+func containsDiagnostic(diagnostics []protocol.Diagnostic, diagnostic protocol.Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Range == diagnostic.Range && d.Message == diagnostic.Message {
+			return true
+		}
+	}
+
+	return false
+}
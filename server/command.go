@@ -0,0 +1,153 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Commands that can be run with workspace/executeCommand.
+const (
+	// CommandInferSchema infers a schema from all documents in a folder.
+	// Arguments are the folder's URI and the URI of the schema to write, both optional.
+	CommandInferSchema = "dyml.inferSchema"
+	// CommandGenerateGo generates Go types for a document or schema.
+	// Arguments are the document's URI and the URI of the Go file to write, which is optional.
+	CommandGenerateGo = "dyml.generateGo"
+)
+
+// Commands is the list of all commands we announce to the client.
+var Commands = []string{CommandInferSchema, CommandGenerateGo}
+
+// inferredSchemaName is the file name of inferred schemas, if the user did not choose one.
+const inferredSchemaName = "inferred.schema.dyml"
+
+// ExecuteCommand runs a command and returns its result.
+func (s *Server) ExecuteCommand(params *protocol.ExecuteCommandParams) interface{} {
+	var args []string
+
+	for _, raw := range params.Arguments {
+		var arg string
+		if err := json.Unmarshal(raw, &arg); err != nil {
+			s.showMessage(protocol.Error, fmt.Sprintf("invalid argument for %s: %s", params.Command, err))
+
+			return nil
+		}
+
+		args = append(args, arg)
+	}
+
+	switch params.Command {
+	case CommandInferSchema:
+		return s.inferSchema(args)
+	case CommandGenerateGo:
+		return s.generateGo(args)
+	default:
+		s.showMessage(protocol.Error, fmt.Sprintf("unknown command %s", params.Command))
+
+		return nil
+	}
+}
+
+// inferSchema infers a schema for a folder, which defaults to the first workspace folder,
+// and writes it to a file. The URI of that file is returned.
+func (s *Server) inferSchema(args []string) interface{} {
+	var folder, output protocol.DocumentURI
+
+	if len(args) > 0 {
+		folder = protocol.DocumentURI(args[0])
+	} else if len(s.workspaceFolders) > 0 {
+		folder = s.workspaceFolders[0]
+	} else {
+		s.showMessage(protocol.Error, "there is no folder to infer a schema from")
+
+		return nil
+	}
+
+	if len(args) > 1 {
+		output = protocol.DocumentURI(args[1])
+	} else {
+		output = pathToURI(filepath.Join(uriToPath(folder), inferredSchemaName))
+	}
+
+	schema, errs := InferSchemaDir(uriToPath(folder))
+	if err := os.WriteFile(uriToPath(output), []byte(schema.Format()), 0o644); err != nil {
+		s.showMessage(protocol.Error, fmt.Sprintf("failed to write schema: %s", err))
+
+		return nil
+	}
+
+	message := fmt.Sprintf("Schema written to %s", uriToPath(output))
+	if len(errs) > 0 {
+		message += fmt.Sprintf(", %d files were skipped because of errors", len(errs))
+	}
+
+	s.showMessage(protocol.Info, message)
+
+	return output
+}
+
+// generateGo generates Go types for a document and writes them to a file next to it.
+// The document is used as a schema, if it is one, otherwise the schema is inferred from it.
+// The URI of the written file is returned.
+func (s *Server) generateGo(args []string) interface{} {
+	if len(args) == 0 {
+		s.showMessage(protocol.Error, "there is no document to generate Go types for")
+
+		return nil
+	}
+
+	uri := protocol.DocumentURI(args[0])
+	path := uriToPath(uri)
+
+	output := pathToURI(generatedGoPath(path))
+	if len(args) > 1 {
+		output = protocol.DocumentURI(args[1])
+	}
+
+	content, err := s.readFile(uri)
+	if err != nil {
+		s.showMessage(protocol.Error, fmt.Sprintf("failed to read %s: %s", path, err))
+
+		return nil
+	}
+
+	doc := ParseDocument(File{Uri: uri, Content: content})
+	if doc.Err != nil {
+		s.showMessage(protocol.Error, fmt.Sprintf("cannot generate Go types for a document with errors: %s", doc.Err))
+
+		return nil
+	}
+
+	schema, err := schemaOrInferred([]*Document{doc})
+	if err != nil {
+		s.showMessage(protocol.Error, fmt.Sprintf("failed to read schema: %s", err))
+
+		return nil
+	}
+
+	src, err := GenerateGo(schema, GoPackageName(filepath.Dir(uriToPath(output))), goTypeName(path))
+	if err == nil {
+		err = os.WriteFile(uriToPath(output), []byte(src), 0o644)
+	}
+
+	if err != nil {
+		s.showMessage(protocol.Error, fmt.Sprintf("failed to generate Go types: %s", err))
+
+		return nil
+	}
+
+	s.showMessage(protocol.Info, fmt.Sprintf("Go types written to %s", uriToPath(output)))
+
+	return output
+}
+
+// showMessage shows a message to the user.
+func (s *Server) showMessage(messageType protocol.MessageType, message string) {
+	_ = SendNotification("window/showMessage", protocol.ShowMessageParams{
+		Type:    messageType,
+		Message: message,
+	})
+}
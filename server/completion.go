@@ -0,0 +1,493 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CompletionTriggerCharacters are the characters that make the editor ask for completions.
+var CompletionTriggerCharacters = []string{"#", "@"}
+
+// nameStats counts how often element names and attribute keys appear in documents.
+type nameStats struct {
+	// elements maps the path of a parent element to the names of its children.
+	elements map[string]map[string]int
+	// attributes maps the path of an element to its attribute keys.
+	attributes map[string]map[string]int
+	// elementTotals and attributeTotals count names regardless of their path.
+	elementTotals   map[string]int
+	attributeTotals map[string]int
+}
+
+func newNameStats() *nameStats {
+	return &nameStats{
+		elements:        make(map[string]map[string]int),
+		attributes:      make(map[string]map[string]int),
+		elementTotals:   make(map[string]int),
+		attributeTotals: make(map[string]int),
+	}
+}
+
+// add counts all names in the document. Names touching the position skip are ignored,
+// so that the name that is currently being typed is not suggested.
+func (n *nameStats) add(doc *Document, skip *protocol.Position) {
+	doc.Root.Walk(func(node *Node) bool {
+		if !node.IsElement() {
+			return false
+		}
+
+		if !node.IsRoot() && (skip == nil || !rangeContains(node.NameRange, *skip)) {
+			increment(n.elements, node.Parent.Path(), node.Name)
+			n.elementTotals[node.Name]++
+		}
+
+		for _, attr := range node.Attributes {
+			if skip == nil || !rangeContains(attr.KeyRange, *skip) {
+				increment(n.attributes, node.Path(), attr.Key)
+				n.attributeTotals[attr.Key]++
+			}
+		}
+
+		return true
+	})
+}
+
+func increment(counts map[string]map[string]int, path, name string) {
+	if counts[path] == nil {
+		counts[path] = make(map[string]int)
+	}
+
+	counts[path][name]++
+}
+
+// completionContext describes what is being typed at the cursor.
+type completionContext struct {
+	// container is the element whose block contains the cursor.
+	container *Node
+	// g2 is true if the cursor is inside a G2 block.
+	g2 bool
+	// trigger is "#", "@" or "@@" if the word at the cursor is preceded by it.
+	trigger string
+	// wordRange covers the identifier that is being typed.
+	wordRange protocol.Range
+	// triggerRange covers the trigger and the word.
+	triggerRange protocol.Range
+	// valueOf is the key of the attribute whose value is being typed, if any.
+	// In that case wordRange covers the part of the value before the cursor.
+	valueOf string
+}
+
+// attributeValueStart matches the beginning of an attribute value that is not yet closed,
+// '@key{value' in G1 and '@key="value' in G2.
+var attributeValueStart = regexp.MustCompile(`@@?([A-Za-z0-9_.]+)(?:\{([^}]*)|="([^"]*))$`)
+
+func newCompletionContext(doc *Document, pos protocol.Position) completionContext {
+	ctx := completionContext{
+		container: doc.ContainerAt(pos),
+	}
+	ctx.g2 = ctx.container.G2 && !ctx.container.IsRoot()
+
+	offset := doc.Offset(pos)
+	content := doc.Content
+
+	lineStart := doc.Offset(protocol.Position{Line: pos.Line})
+	if match := attributeValueStart.FindStringSubmatch(content[lineStart:offset]); match != nil {
+		ctx.valueOf = match[1]
+		ctx.wordRange = protocol.Range{
+			Start: protocol.Position{Line: pos.Line, Character: pos.Character - runeCount(match[2]+match[3])},
+			End:   pos,
+		}
+
+		return ctx
+	}
+
+	// Walk back over the identifier that is being typed.
+	wordStart := offset
+	for wordStart > 0 && isIdentChar(content[wordStart-1]) {
+		wordStart--
+	}
+
+	triggerStart := wordStart
+	switch before := content[:wordStart]; {
+	case strings.HasSuffix(before, "@@"):
+		triggerStart -= 2
+	case strings.HasSuffix(before, "@"), strings.HasSuffix(before, "#"):
+		triggerStart--
+	}
+
+	ctx.trigger = content[triggerStart:wordStart]
+	ctx.wordRange = protocol.Range{
+		Start: protocol.Position{Line: pos.Line, Character: pos.Character - runeCount(content[wordStart:offset])},
+		End:   pos,
+	}
+	ctx.triggerRange = protocol.Range{
+		Start: protocol.Position{Line: pos.Line, Character: pos.Character - runeCount(content[triggerStart:offset])},
+		End:   pos,
+	}
+
+	return ctx
+}
+
+// ownerBefore returns the element that an attribute at the cursor would belong to.
+// This is the last element inside the container that was opened before the cursor.
+func (c completionContext) ownerBefore() *Node {
+	owner := c.container
+
+	c.container.Walk(func(node *Node) bool {
+		if !node.IsElement() {
+			return false
+		}
+
+		// Elements inside a block that is already closed cannot be the owner.
+		if node != c.container && node.BlockRange != nil && positionBefore(node.BlockRange.End, c.wordRange.Start) {
+			owner = node
+
+			return false
+		}
+
+		if node != c.container && positionBefore(node.NameRange.Start, c.wordRange.Start) {
+			owner = node
+		}
+
+		return true
+	})
+
+	return owner
+}
+
+// Completion suggests element names, attribute keys and snippets at the cursor.
+// Names are learned from all open documents and ranked by how often they appear at the same path.
+func (s *Server) Completion(params *protocol.CompletionParams) protocol.CompletionList {
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return protocol.CompletionList{Items: []protocol.CompletionItem{}}
+	}
+
+	doc := ParseDocument(file)
+	ctx := newCompletionContext(doc, params.Position)
+
+	stats := newNameStats()
+	for uri, other := range s.files {
+		if uri == file.Uri {
+			stats.add(doc, &params.Position)
+		} else {
+			stats.add(ParseDocument(other), nil)
+		}
+	}
+
+	items := []protocol.CompletionItem{}
+
+	// Schemas know exactly what is allowed, so we prefer them over names we learned.
+	var container, owner *ElementSchema
+	if schema := s.schemaOf(doc); schema != nil {
+		container = schema.ElementFor(ctx.container)
+		owner = schema.ElementFor(ctx.ownerBefore())
+	}
+
+	switch {
+	case ctx.valueOf != "":
+		items = append(items, valueCompletions(ctx, owner)...)
+	case ctx.trigger == "@@":
+		items = append(items, attributeCompletions(ctx, stats)...)
+	case ctx.trigger == "@":
+		if owner != nil && !owner.Open {
+			items = append(items, schemaAttributeCompletions(ctx, owner)...)
+		} else {
+			items = append(items, attributeCompletions(ctx, stats)...)
+		}
+	default:
+		// Without a trigger in G1 we are in text and need to define elements with a '#'.
+		prefix := "#"
+		if ctx.g2 || ctx.trigger == "#" {
+			prefix = ""
+		}
+
+		if container != nil && !container.Open {
+			items = append(items, schemaElementCompletions(ctx, container, prefix)...)
+		} else {
+			items = append(items, elementCompletions(ctx, stats, prefix)...)
+		}
+
+		items = append(items, snippetCompletions(ctx)...)
+	}
+
+	return protocol.CompletionList{
+		Items: items,
+	}
+}
+
+// elementCompletions suggests names of elements that appear as children of elements at the same path.
+// prefix is inserted before the name.
+func elementCompletions(ctx completionContext, stats *nameStats, prefix string) []protocol.CompletionItem {
+	samePath := stats.elements[ctx.container.Path()]
+
+	return rankedCompletions(samePath, stats.elementTotals, func(name string) protocol.CompletionItem {
+		return protocol.CompletionItem{
+			Label:      name,
+			Kind:       protocol.ClassCompletion,
+			FilterText: name,
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.wordRange,
+				NewText: prefix + name,
+			},
+		}
+	})
+}
+
+// attributeCompletions suggests attribute keys that appear on elements at the same path.
+// Forwarded attributes belong to the next element, so all keys of the container's children are suggested.
+func attributeCompletions(ctx completionContext, stats *nameStats) []protocol.CompletionItem {
+	samePath := make(map[string]int)
+
+	if ctx.trigger == "@@" {
+		for name := range stats.elements[ctx.container.Path()] {
+			path := joinPath(ctx.container.Path(), name)
+			for key, count := range stats.attributes[path] {
+				samePath[key] += count
+			}
+		}
+	} else {
+		for key, count := range stats.attributes[ctx.ownerBefore().Path()] {
+			samePath[key] = count
+		}
+	}
+
+	return rankedCompletions(samePath, stats.attributeTotals, func(key string) protocol.CompletionItem {
+		// Complete the value brackets depending on the grammar.
+		snippet := key + "{$1}"
+		if ctx.g2 {
+			snippet = key + `="$1"`
+		}
+
+		return protocol.CompletionItem{
+			Label:            key,
+			Kind:             protocol.PropertyCompletion,
+			FilterText:       key,
+			InsertTextFormat: protocol.SnippetTextFormat,
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.wordRange,
+				NewText: snippet,
+			},
+		}
+	})
+}
+
+// schemaElementCompletions suggests the child elements the schema allows in the container,
+// unless it already contains as many of them as allowed.
+func schemaElementCompletions(ctx completionContext, container *ElementSchema, prefix string) []protocol.CompletionItem {
+	counts := make(map[string]int)
+	for _, child := range ctx.container.Elements() {
+		if !rangeContains(child.NameRange, ctx.wordRange.End) {
+			counts[child.Name]++
+		}
+	}
+
+	var items []protocol.CompletionItem
+
+	for i, child := range container.Children {
+		if child.Max >= 0 && counts[child.Name] >= child.Max {
+			continue
+		}
+
+		item := protocol.CompletionItem{
+			Label:         child.Name,
+			Kind:          protocol.ClassCompletion,
+			Detail:        "element",
+			Documentation: child.Doc,
+			FilterText:    child.Name,
+			SortText:      fmt.Sprintf("%05d", i),
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.wordRange,
+				NewText: prefix + child.Name,
+			},
+		}
+
+		if child.Deprecated != "" {
+			item.Tags = []protocol.CompletionItemTag{protocol.ComplDeprecated}
+			item.SortText = "~" + item.SortText
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// schemaAttributeCompletions suggests the attributes the schema allows on the owner,
+// that are not yet set. Required attributes come first.
+func schemaAttributeCompletions(ctx completionContext, owner *ElementSchema) []protocol.CompletionItem {
+	node := ctx.ownerBefore()
+
+	var items []protocol.CompletionItem
+
+	for i, attr := range owner.Attributes {
+		if existing := node.Attribute(attr.Name); existing != nil && !rangeContains(existing.KeyRange, ctx.wordRange.End) {
+			continue
+		}
+
+		snippet := attr.Name + "{$1}"
+		if ctx.g2 {
+			snippet = attr.Name + `="$1"`
+		}
+
+		item := protocol.CompletionItem{
+			Label:            attr.Name,
+			Kind:             protocol.PropertyCompletion,
+			Detail:           attr.Detail(),
+			Documentation:    attr.Doc,
+			FilterText:       attr.Name,
+			SortText:         fmt.Sprintf("1%05d", i),
+			InsertTextFormat: protocol.SnippetTextFormat,
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.wordRange,
+				NewText: snippet,
+			},
+		}
+
+		if attr.Required {
+			item.SortText = fmt.Sprintf("0%05d", i)
+		}
+
+		if attr.Deprecated != "" {
+			item.Tags = []protocol.CompletionItemTag{protocol.ComplDeprecated}
+			item.SortText = "~" + item.SortText
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// valueCompletions suggests the values of enum and bool attributes.
+func valueCompletions(ctx completionContext, owner *ElementSchema) []protocol.CompletionItem {
+	if owner == nil {
+		return nil
+	}
+
+	attr := owner.Attribute(ctx.valueOf)
+	if attr == nil {
+		return nil
+	}
+
+	var items []protocol.CompletionItem
+
+	for i, value := range attr.Values() {
+		items = append(items, protocol.CompletionItem{
+			Label:      value,
+			Kind:       protocol.EnumMemberCompletion,
+			Detail:     "@" + attr.Name,
+			FilterText: value,
+			SortText:   fmt.Sprintf("%05d", i),
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.wordRange,
+				NewText: value,
+			},
+		})
+	}
+
+	return items
+}
+
+// rankedCompletions builds completion items for all names, ranking names at the same path
+// before names that were seen elsewhere, and more frequent names before less frequent ones.
+func rankedCompletions(samePath, totals map[string]int, newItem func(name string) protocol.CompletionItem) []protocol.CompletionItem {
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if samePath[a] != samePath[b] {
+			return samePath[a] > samePath[b]
+		}
+
+		if totals[a] != totals[b] {
+			return totals[a] > totals[b]
+		}
+
+		return a < b
+	})
+
+	var items []protocol.CompletionItem
+
+	for i, name := range names {
+		item := newItem(name)
+		item.SortText = fmt.Sprintf("%05d", i)
+
+		if count := samePath[name]; count > 0 {
+			item.Detail = fmt.Sprintf("used %d× here", count)
+		} else {
+			item.Detail = fmt.Sprintf("used %d× elsewhere", totals[name])
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// snippetCompletions suggests grammar constructs that are valid at the cursor.
+// They replace the trigger, so that typing '#' and selecting a snippet does not duplicate it.
+func snippetCompletions(ctx completionContext) []protocol.CompletionItem {
+	type snippet struct {
+		label, detail, text string
+	}
+
+	var snippets []snippet
+
+	if ctx.g2 {
+		snippets = []snippet{
+			{"@@", "forwarded attribute", `@@${1:key}="${2:value}"`},
+			{"//", "comment", "// $0"},
+		}
+	} else {
+		snippets = []snippet{
+			{"#!", "G2 element", "#! ${1:name} {\n\t$0\n}"},
+			{"@@", "forwarded attribute", "@@${1:key}{${2:value}}"},
+			{"#?", "comment", "#? $0"},
+		}
+	}
+
+	var items []protocol.CompletionItem
+
+	for _, snip := range snippets {
+		items = append(items, protocol.CompletionItem{
+			Label:            snip.label,
+			Kind:             protocol.SnippetCompletion,
+			Detail:           snip.detail,
+			FilterText:       snip.label,
+			SortText:         "~" + snip.label, // Sort snippets after names.
+			InsertTextFormat: protocol.SnippetTextFormat,
+			TextEdit: &protocol.TextEdit{
+				Range:   ctx.triggerRange,
+				NewText: snip.text,
+			},
+		})
+	}
+
+	return items
+}
+
+// joinPath appends a name to an element path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "/" + name
+}
+
+// isIdentChar returns true for all characters that can be part of an identifier, including the dots between its parts.
+func isIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.'
+}
+
+// runeCount returns the number of characters in s, which is what positions count in.
+func runeCount(s string) uint32 {
+	return uint32(len([]rune(s)))
+}
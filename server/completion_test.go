@@ -0,0 +1,36 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"testing"
+)
+
+func TestCompletionContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		pos     protocol.Position
+		trigger string
+		word    uint32
+		valueOf string
+	}{
+		{"element", "#ab", protocol.Position{Character: 3}, "#", 1, ""},
+		{"dotted element", "#a.b", protocol.Position{Character: 4}, "#", 1, ""},
+		{"inside dotted element", "#svg.rect", protocol.Position{Character: 6}, "#", 1, ""},
+		{"dotted attribute", "#a @xml.lang", protocol.Position{Character: 12}, "@", 4, ""},
+		{"forwarded attribute", "#a {@@x.y", protocol.Position{Character: 9}, "@@", 6, ""},
+		{"dotted attribute value", "#a @xml.lang{de", protocol.Position{Character: 15}, "", 13, "xml.lang"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ParseDocument(File{Uri: "file:///test.dyml", Content: tt.content})
+			ctx := newCompletionContext(doc, tt.pos)
+
+			if ctx.trigger != tt.trigger || ctx.wordRange.Start.Character != tt.word || ctx.valueOf != tt.valueOf {
+				t.Errorf("got trigger %q, word from %d, value of %q, want %q, %d, %q",
+					ctx.trigger, ctx.wordRange.Start.Character, ctx.valueOf, tt.trigger, tt.word, tt.valueOf)
+			}
+		})
+	}
+}
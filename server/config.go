@@ -0,0 +1,128 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config holds the settings of the "dyml" section in the editor's configuration.
+type Config struct {
+	// Schemas maps schema files to glob patterns of documents, that should be validated against them.
+	// Relative schema paths and patterns are resolved against the workspace folders.
+	Schemas map[string][]string `json:"schemas"`
+	// GoTypes maps Go types to glob patterns of documents, that are unmarshalled into them.
+	// Types are written as package and name, e.g. "./config.Config" or "example.com/app/config.Config".
+	GoTypes map[string][]string `json:"goTypes"`
+	// GoStrict checks documents against Go types as if they were unmarshalled in strict mode.
+	GoStrict bool `json:"goStrict"`
+	// Lint configures the lint rules.
+	Lint LintConfig `json:"lint"`
+	// References configures the attributes that define and reference identifiers.
+	References ReferencesConfig `json:"references"`
+	// Includes are the constructs that include other documents, like "include@src" for #include @src{other.dyml}.
+	Includes []string `json:"includes"`
+	// XML holds the options for encoding documents as XML.
+	XML XMLOptions `json:"xml"`
+	// Import holds the options for converting XML, JSON and YAML to DYML.
+	Import ImportOptions `json:"import"`
+	// RenameScope is one of "node", "document" or "workspace" (the default) and tells what a rename changes.
+	RenameScope string `json:"renameScope"`
+}
+
+// ReferencesConfig lists the keys of attributes that define identifiers, like "id",
+// and of attributes that reference them, like "ref". Schemas can declare them with the types "id" and "ref" as well.
+type ReferencesConfig struct {
+	IDs  []string `json:"ids"`
+	Refs []string `json:"refs"`
+}
+
+// parseConfig reads our settings from what the client sent as initialization options or
+// as changed configuration. These are either the "dyml" section itself or contain it.
+func parseConfig(settings interface{}) (Config, error) {
+	var config Config
+
+	if settings == nil {
+		return config, nil
+	}
+
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return config, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	var section struct {
+		Dyml *json.RawMessage `json:"dyml"`
+	}
+
+	if err := json.Unmarshal(raw, &section); err == nil && section.Dyml != nil {
+		raw = *section.Dyml
+	}
+
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal settings: %w", err)
+	}
+
+	return config, nil
+}
+
+// findDirective finds the first comment in a document that starts with the directive's name,
+// like "#? dyml-schema config.schema.dyml". It returns the remaining words of the comment's first line
+// and the comment's range. Directives are on the first line, as G1 comments go on until the next element
+// and take the text after them.
+func findDirective(doc *Document, name string) ([]string, protocol.Range, bool) {
+	var (
+		args  []string
+		where protocol.Range
+		found bool
+	)
+
+	doc.Root.Walk(func(node *Node) bool {
+		if node.Comment != nil && !found {
+			fields := strings.Fields(strings.SplitN(*node.Comment, "\n", 2)[0])
+			if len(fields) > 0 && fields[0] == name {
+				args, where, found = fields[1:], node.Range, true
+			}
+		}
+
+		return !found
+	})
+
+	return args, where, found
+}
+
+// configured finds the entry of a setting that maps values to glob patterns, whose patterns
+// match the document. It returns the entry's key and the workspace folder the patterns matched in.
+// Keys are tried in sorted order to get the same result, should a document match multiple ones.
+func (s *Server) configured(doc *Document, entries map[string][]string) (string, string, bool) {
+	docPath := uriToPath(doc.Uri)
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, folder := range s.workspaceFolders {
+			folderPath := uriToPath(folder)
+
+			rel, err := filepath.Rel(folderPath, docPath)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+
+			for _, pattern := range entries[key] {
+				if matchGlob(pattern, filepath.ToSlash(rel)) {
+					return key, folderPath, true
+				}
+			}
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,32 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"testing"
+)
+
+func TestConfigured(t *testing.T) {
+	tests := []struct {
+		uri  protocol.DocumentURI
+		want bool
+	}{
+		{"file:///ws/config.dyml", true},
+		{"file:///ws/sub/config.dyml", true},
+		{"file:///ws/..config.dyml", true},
+		{"file:///ws/..sub/config.dyml", true},
+		{"file:///other/config.dyml", false},
+		{"file:///config.dyml", false},
+	}
+
+	s := NewServer()
+	s.workspaceFolders = []protocol.DocumentURI{"file:///ws"}
+
+	for _, tt := range tests {
+		doc := ParseDocument(File{Uri: tt.uri, Content: "#a {x}\n"})
+
+		_, _, got := s.configured(doc, map[string][]string{"a.schema.dyml": {"**/*.dyml"}})
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxPreviewLines limits how much of an element is shown when hovering a reference to it.
+const maxPreviewLines = 10
+
+// idKind tells whether an attribute defines or references an identifier.
+type idKind int
+
+const (
+	notAnID idKind = iota
+	idDefinition
+	idReference
+)
+
+// crossRef is the value of an attribute that defines an identifier, like @id{x}, or references one, like @ref{x}.
+// Identifiers are shared by all documents of the workspace.
+type crossRef struct {
+	id       string
+	kind     idKind
+	location protocol.Location
+	// preview is the source of the element that defines the identifier, only set for definitions.
+	preview string
+}
+
+// idKindOf tells if the attribute defines or references an identifier. The attribute's key is looked up
+// in the references setting first, then the attribute's type in the schema is used.
+func (s *Server) idKindOf(schema *Schema, node *Node, attr *Attribute) idKind {
+	for _, key := range s.config.References.IDs {
+		if key == attr.Key {
+			return idDefinition
+		}
+	}
+
+	for _, key := range s.config.References.Refs {
+		if key == attr.Key {
+			return idReference
+		}
+	}
+
+	if schema == nil {
+		return notAnID
+	}
+
+	if element := schema.ElementFor(node); element != nil {
+		if attrSchema := element.Attribute(attr.Key); attrSchema != nil {
+			switch attrSchema.Type {
+			case TypeID:
+				return idDefinition
+			case TypeRef:
+				return idReference
+			}
+		}
+	}
+
+	return notAnID
+}
+
+// crossRefsOf returns all definitions and references of identifiers in a document.
+func (s *Server) crossRefsOf(doc *Document) []crossRef {
+	var refs []crossRef
+
+	schema := s.schemaOf(doc)
+
+	doc.Root.Walk(func(node *Node) bool {
+		for _, attr := range node.Attributes {
+			kind := s.idKindOf(schema, node, attr)
+			if kind == notAnID || strings.TrimSpace(attr.Value) == "" {
+				continue
+			}
+
+			ref := crossRef{
+				id:       attr.Value,
+				kind:     kind,
+				location: protocol.Location{URI: doc.Uri, Range: valueRange(attr)},
+			}
+
+			if kind == idDefinition {
+				ref.preview = elementPreview(doc, node)
+			}
+
+			refs = append(refs, ref)
+		}
+
+		return true
+	})
+
+	return refs
+}
+
+// elementPreview returns the lines of an element, without their common indentation.
+func elementPreview(doc *Document, node *Node) string {
+	lines := strings.Split(doc.Content, "\n")
+	start, end := int(node.Range.Start.Line), int(node.Range.End.Line)
+
+	if end >= len(lines) {
+		end = len(lines) - 1
+	}
+
+	lines = lines[start : end+1]
+	if len(lines) > maxPreviewLines {
+		lines = append(lines[:maxPreviewLines], "...")
+	}
+
+	first := lines[0]
+	indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.TrimPrefix(line, indent), "\r")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// sameDefinitions returns true if both lists define the same identifiers at the same places.
+func sameDefinitions(a, b []crossRef) bool {
+	var defA, defB []crossRef
+
+	for _, ref := range a {
+		if ref.kind == idDefinition {
+			defA = append(defA, ref)
+		}
+	}
+
+	for _, ref := range b {
+		if ref.kind == idDefinition {
+			defB = append(defB, ref)
+		}
+	}
+
+	if len(defA) != len(defB) {
+		return false
+	}
+
+	for i := range defA {
+		if defA[i].id != defB[i].id || defA[i].location != defB[i].location {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasCrossRefs returns true if any file of the workspace defines or references identifiers.
+func (s *Server) hasCrossRefs() bool {
+	for _, refs := range s.crossRefs {
+		if len(refs) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// crossRefsTo returns all definitions or references of an identifier in the workspace, sorted by their location.
+func (s *Server) crossRefsTo(id string, kind idKind) []crossRef {
+	var found []crossRef
+
+	for _, refs := range s.crossRefs {
+		for _, ref := range refs {
+			if ref.id == id && ref.kind == kind {
+				found = append(found, ref)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].location, found[j].location
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+
+		return positionBefore(a.Range.Start, b.Range.Start)
+	})
+
+	return found
+}
+
+// crossRefAt returns the definition or reference of an identifier at the position.
+func (s *Server) crossRefAt(doc *Document, pos protocol.Position) (crossRef, bool) {
+	for _, ref := range s.crossRefsOf(doc) {
+		if rangeContains(ref.location.Range, pos) {
+			return ref, true
+		}
+	}
+
+	return crossRef{}, false
+}
+
+// crossRefProblems reports references to identifiers that are not defined anywhere in the workspace,
+// and identifiers that are defined more than once.
+func (s *Server) crossRefProblems(doc *Document) []Problem {
+	var problems []Problem
+
+	for _, ref := range s.crossRefsOf(doc) {
+		definitions := s.crossRefsTo(ref.id, idDefinition)
+
+		switch ref.kind {
+		case idDefinition:
+			var related []protocol.DiagnosticRelatedInformation
+
+			for _, other := range definitions {
+				if other.location != ref.location {
+					related = append(related, protocol.DiagnosticRelatedInformation{Location: other.location, Message: "also defined here"})
+				}
+			}
+
+			if len(related) == 0 {
+				continue
+			}
+
+			problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+				Range:              ref.location.Range,
+				Severity:           protocol.SeverityError,
+				Code:               "duplicate-id",
+				CodeDescription:    codeDescription("duplicate-id"),
+				Message:            fmt.Sprintf("id '%s' is defined %d times", ref.id, len(related)+1),
+				RelatedInformation: related,
+			}})
+		case idReference:
+			if len(definitions) > 0 {
+				continue
+			}
+
+			problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+				Range:           ref.location.Range,
+				Severity:        protocol.SeverityError,
+				Code:            "dangling-reference",
+				CodeDescription: codeDescription("dangling-reference"),
+				Message:         fmt.Sprintf("id '%s' is not defined", ref.id),
+			}})
+		}
+	}
+
+	return problems
+}
+
+// Definition goes from a reference to the element that defines the identifier,
+// or from the path of an include to the included document.
+func (s *Server) Definition(params *protocol.DefinitionParams) []protocol.Location {
+	locations := []protocol.Location{}
+
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return locations
+	}
+
+	doc := ParseDocument(file)
+
+	if inc, ok := s.includeAt(doc, params.Position); ok {
+		return append(locations, protocol.Location{URI: inc.uri})
+	}
+
+	ref, ok := s.crossRefAt(doc, params.Position)
+	if !ok {
+		return locations
+	}
+
+	for _, definition := range s.crossRefsTo(ref.id, idDefinition) {
+		locations = append(locations, definition.location)
+	}
+
+	return locations
+}
+
+// crossReferences returns the references to the identifier, and its definitions if they are asked for.
+func (s *Server) crossReferences(ref crossRef, includeDeclaration bool) []protocol.Location {
+	locations := []protocol.Location{}
+
+	if includeDeclaration {
+		for _, definition := range s.crossRefsTo(ref.id, idDefinition) {
+			locations = append(locations, definition.location)
+		}
+	}
+
+	for _, reference := range s.crossRefsTo(ref.id, idReference) {
+		locations = append(locations, reference.location)
+	}
+
+	return locations
+}
+
+// crossRefHover previews the elements a reference points to, or counts the references to a definition.
+func (s *Server) crossRefHover(ref crossRef) *protocol.Hover {
+	var sb strings.Builder
+
+	if ref.kind == idReference {
+		definitions := s.crossRefsTo(ref.id, idDefinition)
+		if len(definitions) == 0 {
+			return nil
+		}
+
+		for i, definition := range definitions {
+			if i > 0 {
+				sb.WriteString("\n\n---\n\n")
+			}
+
+			fmt.Fprintf(&sb, "```dyml\n%s\n```\n\n[%s:%d](%s#L%d)", definition.preview,
+				filepath.Base(uriToPath(definition.location.URI)), definition.location.Range.Start.Line+1,
+				definition.location.URI, definition.location.Range.Start.Line+1)
+		}
+	} else {
+		switch references := len(s.crossRefsTo(ref.id, idReference)); references {
+		case 0:
+			fmt.Fprintf(&sb, "**%s** is not referenced", ref.id)
+		case 1:
+			fmt.Fprintf(&sb, "**%s** is referenced once", ref.id)
+		default:
+			fmt.Fprintf(&sb, "**%s** is referenced %d times", ref.id, references)
+		}
+	}
+
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: sb.String()},
+		Range:    ref.location.Range,
+	}
+}
@@ -0,0 +1,301 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeXMLParams are the parameters of custom/decodeXML.
+type DecodeXMLParams struct {
+	// XML is the document to convert.
+	XML string `json:"xml"`
+	// Grammar is "g1" or "g2", G1 is used if it is empty.
+	Grammar string `json:"grammar"`
+	// RootName is the name of the root element, which is left out like the root of our XML. It is "root" if empty.
+	RootName string `json:"rootName"`
+}
+
+// DecodeXMLResult is the result of custom/decodeXML.
+type DecodeXMLResult struct {
+	// DYML is the converted document. When there are errors, it contains everything up to the first one.
+	DYML string `json:"dyml"`
+	// Errors are the syntax errors of the XML, followed by warnings about comments that are left out.
+	Errors []protocol.Diagnostic `json:"errors"`
+	// Success is true if the XML was converted without errors, there may still be warnings.
+	Success bool `json:"success"`
+}
+
+// xmlName returns the name of an element or attribute with its namespace prefix, like "svg:rect".
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+// lineError is an error in a line of the input, counting from 1.
+type lineError struct {
+	line int
+	msg  string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// parseXML reads XML into nodes. Element and attribute names are turned into valid DYML names, text and
+// comments are kept without the whitespace around them, processing instructions and directives are left out.
+// An element with the root name and without attributes, which is all there is, is left out and its children
+// are returned instead. On errors, all nodes up to the error are returned.
+func parseXML(src, rootName string) ([]*Node, error) {
+	if rootName == "" {
+		rootName = "root"
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(src))
+	// Legacy documents often use the entities of HTML, like &nbsp;.
+	decoder.Entity = xml.HTMLEntity
+
+	root := &Node{Name: "root"}
+	current := root
+
+	// Names are checked against their start here, as RawToken does not do that. Unlike Token,
+	// it keeps the namespace prefixes as they are written.
+	var names []string
+
+	lineAt := func(offset int64) int {
+		return strings.Count(src[:offset], "\n") + 1
+	}
+
+	for {
+		offset := decoder.InputOffset()
+
+		tok, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return root.Children, &lineError{line: syntaxErr.Line, msg: syntaxErr.Msg}
+		}
+
+		if err != nil {
+			return root.Children, &lineError{line: lineAt(offset), msg: err.Error()}
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			node := &Node{Name: dymlName(xmlName(tok.Name)), Parent: current}
+			for _, attr := range tok.Attr {
+				node.Attributes = append(node.Attributes, &Attribute{Key: dymlName(xmlName(attr.Name)), Value: attr.Value})
+			}
+
+			current.Children = append(current.Children, node)
+			current = node
+			names = append(names, xmlName(tok.Name))
+		case xml.EndElement:
+			if len(names) == 0 || names[len(names)-1] != xmlName(tok.Name) {
+				return root.Children, &lineError{line: lineAt(offset), msg: fmt.Sprintf("unexpected end element </%s>", xmlName(tok.Name))}
+			}
+
+			current = current.Parent
+			names = names[:len(names)-1]
+		case xml.CharData:
+			if text := strings.TrimSpace(string(tok)); text != "" {
+				current.Children = append(current.Children, &Node{Text: &text, Parent: current})
+			}
+		case xml.Comment:
+			comment := strings.TrimSpace(string(tok))
+			line := uint32(lineAt(offset) - 1)
+			current.Children = append(current.Children, &Node{
+				Comment: &comment,
+				Parent:  current,
+				Range:   protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line}},
+			})
+		}
+	}
+
+	if len(names) > 0 {
+		return root.Children, &lineError{line: lineAt(int64(len(src))), msg: fmt.Sprintf("element <%s> is not closed", names[len(names)-1])}
+	}
+
+	return unwrapRoot(root, rootName).Children, nil
+}
+
+// unwrapRoot replaces the only element of root with its children, if it is called rootName and has no attributes.
+// Comments around it stay where they are.
+func unwrapRoot(root *Node, rootName string) *Node {
+	var found *Node
+
+	for _, child := range root.Children {
+		if !child.IsElement() {
+			continue
+		}
+
+		if found != nil {
+			return root
+		}
+
+		found = child
+	}
+
+	if found == nil || found.Name != rootName || len(found.Attributes) > 0 {
+		return root
+	}
+
+	var children []*Node
+
+	for _, child := range root.Children {
+		if child != found {
+			children = append(children, child)
+
+			continue
+		}
+
+		for _, grandChild := range found.Children {
+			grandChild.Parent = root
+			children = append(children, grandChild)
+		}
+	}
+
+	root.Children = children
+
+	return root
+}
+
+// ConvertXML converts XML to DYML in the grammar, which is "g1" or "g2". On errors, the DYML
+// contains everything up to the error. The warnings are about comments that are left out,
+// because G1 cannot hold them where they are.
+func ConvertXML(src, grammar, rootName string) (dyml string, warnings []error, err error) {
+	nodes, err := parseXML(src, rootName)
+
+	dyml, dropped := writeDYML(nodes, grammar)
+	for _, comment := range dropped {
+		warnings = append(warnings, &lineError{
+			line: int(comment.Range.Start.Line) + 1,
+			msg:  "comment is left out, as G1 cannot hold comments in blocks without elements",
+		})
+	}
+
+	return dyml, warnings, err
+}
+
+// DecodeParams are the parameters of custom/decode.
+type DecodeParams struct {
+	// Text is the document to convert.
+	Text string `json:"text"`
+	// Format is "xml", "json" or "yaml". It is guessed from the text if it is empty.
+	Format string `json:"format"`
+	// Options replace the import settings, if they are given.
+	Options *ImportOptions `json:"options,omitempty"`
+}
+
+// DecodeResult is the result of custom/decode.
+type DecodeResult struct {
+	// DYML is the converted document. When there are errors, it contains everything up to the first one.
+	DYML string `json:"dyml"`
+	// Errors are the syntax errors of the text, followed by warnings about comments that are left out.
+	Errors []protocol.Diagnostic `json:"errors"`
+	// Success is true if the text was converted without errors, there may still be warnings.
+	Success bool `json:"success"`
+	// Format is the format of the text, which is the guessed one if the request did not tell.
+	Format string `json:"format"`
+}
+
+// guessFormat returns the format of a text: XML if it starts with '<', JSON if it is valid JSON and YAML otherwise.
+func guessFormat(text string) string {
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(text), "<"):
+		return FormatXML
+	case json.Valid([]byte(text)):
+		return FormatJSON
+	default:
+		return FormatYAML
+	}
+}
+
+// Decode converts XML, JSON or YAML to DYML, with the options of the request or the import settings.
+func (s *Server) Decode(params *DecodeParams) DecodeResult {
+	failed := func(err string) DecodeResult {
+		return DecodeResult{Errors: []protocol.Diagnostic{{Severity: protocol.SeverityError, Message: err}}, Format: params.Format}
+	}
+
+	options := s.config.Import
+	if params.Options != nil {
+		options = *params.Options
+	}
+
+	switch options.Grammar {
+	case "", GrammarG1, GrammarG2:
+	default:
+		return failed(fmt.Sprintf("unknown grammar '%s', expected g1 or g2", options.Grammar))
+	}
+
+	format := params.Format
+	if format == "" {
+		format = guessFormat(params.Text)
+	}
+
+	var (
+		dyml     string
+		warnings []error
+		err      error
+	)
+
+	switch format {
+	case FormatXML:
+		dyml, warnings, err = ConvertXML(params.Text, options.Grammar, options.RootName)
+	case FormatJSON, FormatYAML:
+		dyml, err = ConvertObjects(params.Text, format, options)
+	default:
+		return failed(fmt.Sprintf("unknown format '%s', expected xml, json or yaml", format))
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+
+	var lineErr *lineError
+
+	switch {
+	case errors.As(err, &lineErr):
+		diagnostics = append(diagnostics, lineDiagnostic(lineErr, protocol.SeverityError))
+	case err != nil:
+		return failed(err.Error())
+	}
+
+	for _, warning := range warnings {
+		if errors.As(warning, &lineErr) {
+			diagnostics = append(diagnostics, lineDiagnostic(lineErr, protocol.SeverityWarning))
+		}
+	}
+
+	return DecodeResult{DYML: dyml, Errors: diagnostics, Success: err == nil, Format: format}
+}
+
+// lineDiagnostic returns a diagnostic at the start of the line of an error.
+func lineDiagnostic(err *lineError, severity protocol.DiagnosticSeverity) protocol.Diagnostic {
+	line := uint32(err.line - 1)
+
+	return protocol.Diagnostic{
+		Range:    protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line}},
+		Severity: severity,
+		Message:  err.msg,
+	}
+}
+
+// DecodeXML converts XML to DYML, it is custom/decode for the format "xml".
+func (s *Server) DecodeXML(params *DecodeXMLParams) DecodeXMLResult {
+	options := s.config.Import
+	options.Grammar = params.Grammar
+	options.RootName = params.RootName
+
+	result := s.Decode(&DecodeParams{Text: params.XML, Format: FormatXML, Options: &options})
+
+	return DecodeXMLResult{DYML: result.DYML, Errors: result.Errors, Success: result.Success}
+}
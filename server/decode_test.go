@@ -0,0 +1,167 @@
+package dyml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golangee/dyml/encoder"
+)
+
+// dumpNodes returns the nodes as one line per node, indented by depth, to compare trees from different sources.
+// Text and comments are written without their line breaks, and comments that follow each other are joined,
+// as G2 writes each line of a comment as a comment of its own.
+func dumpNodes(sb *strings.Builder, nodes []*Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for i, node := range nodes {
+		switch {
+		case node.Comment != nil:
+			if i > 0 && nodes[i-1].Comment != nil {
+				sb.WriteString(" ")
+			} else {
+				sb.WriteString(indent + "comment ")
+			}
+
+			sb.WriteString(strings.Join(lines(*node.Comment), " "))
+
+			if i == len(nodes)-1 || nodes[i+1].Comment == nil {
+				sb.WriteString("\n")
+			}
+		case node.Text != nil:
+			sb.WriteString(indent + "text " + strings.Join(lines(*node.Text), " ") + "\n")
+		default:
+			sb.WriteString(indent + node.Name)
+
+			for _, attr := range node.Attributes {
+				sb.WriteString(" @" + attr.Key + "=" + attr.Value)
+			}
+
+			sb.WriteString("\n")
+			dumpNodes(sb, node.Children, depth+1)
+		}
+	}
+}
+
+// parseDump parses XML and dumps its nodes.
+func parseDump(t *testing.T, src string) string {
+	t.Helper()
+
+	nodes, err := parseXML(src, "")
+	if err != nil {
+		t.Fatalf("parsing %s: %v", src, err)
+	}
+
+	var sb strings.Builder
+	dumpNodes(&sb, nodes, 0)
+
+	return sb.String()
+}
+
+// TestConvertXMLRoundTrip converts XML to DYML and back to XML with the encoder of DYML,
+// which must give the same tree in both grammars.
+func TestConvertXMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"attributes", `<user id="1" name="A } b # c"><email type="work">a@b.c</email></user>`},
+		{"repeated", `<list><item>a</item><item>b</item><item/></list>`},
+		{"mixed", `<p>Hello <b>bold</b> and <i>it "quoted" \ #</i> world.<br/>Next line</p>`},
+		{"namespaces", `<svg:svg xmlns:svg="http://www.w3.org/2000/svg"><svg:rect svg:x="1" y="2"/></svg:svg>`},
+		{"comments", `<root>
+			<!-- first # comment } with \ -->
+			<a><!-- before b --><b/>text<!-- before c --><c>x</c></a>
+			<!-- between -->
+			<d>only text</d>
+			<!-- last -->
+		</root>`},
+		{"multiline", "<a>\n  first line\n  second line\n  <!-- a comment\n  over lines -->\n  <b/>\n</a>"},
+	}
+
+	for _, tt := range tests {
+		want := parseDump(t, tt.xml)
+
+		for _, grammar := range []string{GrammarG1, GrammarG2} {
+			t.Run(tt.name+"/"+grammar, func(t *testing.T) {
+				dyml, warnings, err := ConvertXML(tt.xml, grammar, "")
+				if err != nil || len(warnings) > 0 {
+					t.Fatalf("converting: %v %v", err, warnings)
+				}
+
+				var xml strings.Builder
+				if err := encoder.NewXMLEncoder("test.dyml", strings.NewReader(dyml), &xml).Encode(); err != nil {
+					t.Fatalf("encoding %s: %v", dyml, err)
+				}
+
+				if got := parseDump(t, xml.String()); got != want {
+					t.Errorf("DYML:\n%s\ngives:\n%s\nwant:\n%s", dyml, got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestConvertXMLComments checks where comments end up in G1, which cannot hold them everywhere.
+func TestConvertXMLComments(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want string
+		// warnings are the lines of the comments that are left out.
+		warnings []int
+	}{
+		{
+			"before text",
+			"<a><!-- c -->text<b/></a>",
+			"#a {\n    text\n    #? c\n    #b {}\n}\n",
+			nil,
+		},
+		{
+			"end of block",
+			"<a><b/>text<!-- c --></a>",
+			"#a {\n    #? c\n    #b {}\n    text\n}\n",
+			nil,
+		},
+		{
+			"end of document",
+			"<root><a/>text<!-- c --></root>",
+			"#a {}\ntext\n#? c\n",
+			nil,
+		},
+		{
+			"no elements",
+			"<a>\ntext<!-- c --></a>",
+			"#a {\n    text\n}\n",
+			[]int{2},
+		},
+		{
+			"escapes",
+			`<root><!-- \ # } --><a/></root>`,
+			"#? \\\\ \\# }\n#a {}\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dyml, warnings, err := ConvertXML(tt.xml, GrammarG1, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dyml != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", dyml, tt.want)
+			}
+
+			var lines []int
+
+			for _, warning := range warnings {
+				lines = append(lines, warning.(*lineError).line)
+			}
+
+			if len(lines) != len(tt.warnings) || (len(lines) > 0 && lines[0] != tt.warnings[0]) {
+				t.Errorf("got warnings %v, want them in lines %v", warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package dyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mappings of scalars and arrays in JSON and YAML, as set in ImportOptions.
+const (
+	// MapElement maps scalars in objects to elements with the key as name and the value as text.
+	MapElement = "element"
+	// MapAttribute maps scalars in objects to attributes.
+	MapAttribute = "attribute"
+	// MapRepeat maps arrays to an element for each item, all named like the key of the array.
+	MapRepeat = "repeat"
+	// MapWrap maps arrays to an element named like the key, with an element for each item inside.
+	MapWrap = "wrap"
+)
+
+// ImportOptions control how other formats are converted to DYML. The zero value converts JSON and YAML from
+// custom/encode back to the same DYML.
+type ImportOptions struct {
+	// Grammar is "g1" or "g2", G1 is used if it is empty.
+	Grammar string `json:"grammar"`
+	// RootName is the name of the root element of XML, which is left out. It is "root" if empty.
+	RootName string `json:"rootName"`
+	// AttributePrefix marks the keys of scalars that become attributes, "@" if it is empty.
+	AttributePrefix string `json:"attributePrefix"`
+	// TextKey is the key of text, "#text" if it is empty.
+	TextKey string `json:"textKey"`
+	// Scalars is "element" (the default) or "attribute", for scalars without the attribute prefix.
+	Scalars string `json:"scalars"`
+	// Arrays is "repeat" (the default) or "wrap".
+	Arrays string `json:"arrays"`
+	// ItemName is the name of elements for items of arrays that are not in an object, "item" if it is empty.
+	ItemName string `json:"itemName"`
+}
+
+// withDefaults returns the options with defaults for everything that is empty.
+func (o ImportOptions) withDefaults() ImportOptions {
+	if o.AttributePrefix == "" {
+		o.AttributePrefix = "@"
+	}
+
+	if o.TextKey == "" {
+		o.TextKey = "#text"
+	}
+
+	if o.Scalars == "" {
+		o.Scalars = MapElement
+	}
+
+	if o.Arrays == "" {
+		o.Arrays = MapRepeat
+	}
+
+	if o.ItemName == "" {
+		o.ItemName = "item"
+	}
+
+	return o
+}
+
+// check returns an error if the mapping of scalars or arrays is unknown.
+func (o ImportOptions) check() error {
+	if o.Scalars != "" && o.Scalars != MapElement && o.Scalars != MapAttribute {
+		return fmt.Errorf("unknown mapping of scalars '%s', expected element or attribute", o.Scalars)
+	}
+
+	if o.Arrays != "" && o.Arrays != MapRepeat && o.Arrays != MapWrap {
+		return fmt.Errorf("unknown mapping of arrays '%s', expected repeat or wrap", o.Arrays)
+	}
+
+	return nil
+}
+
+// objectMapper converts values of JSON and YAML to nodes, the opposite of jsonBuilder:
+//
+//   - keys with the attribute prefix and a scalar value are attributes
+//   - keys like the text key are text, a list of strings is text in several places
+//   - other keys are elements, or attributes for scalars if they are mapped to attributes
+//   - arrays are elements for each item with the key as name, or an element with the key containing items
+//   - null is an element without children, or an empty attribute
+type objectMapper struct {
+	options ImportOptions
+}
+
+// add adds elements and attributes for the value of a document to the root.
+func (m *objectMapper) add(root *Node, value *yaml.Node) {
+	value = resolveAlias(value)
+
+	switch value.Kind {
+	case yaml.DocumentNode:
+		for _, content := range value.Content {
+			m.add(root, content)
+		}
+	case yaml.MappingNode:
+		m.fields(root, value)
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			m.element(root, m.options.ItemName, item)
+		}
+	case yaml.ScalarNode:
+		addText(root, scalar(value))
+	}
+}
+
+// fields adds the keys of a mapping to an element.
+func (m *objectMapper) fields(parent *Node, mapping *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i].Value
+		value := resolveAlias(mapping.Content[i+1])
+		// The root has no attributes, scalars at the top level are always elements.
+		attribute := !parent.IsRoot() && value.Kind == yaml.ScalarNode
+
+		switch {
+		case mapping.Content[i].Tag == "!!merge":
+			m.merge(parent, value)
+		case key == m.options.TextKey && value.Kind == yaml.SequenceNode:
+			for _, item := range value.Content {
+				addText(parent, scalar(resolveAlias(item)))
+			}
+		case key == m.options.TextKey && value.Kind == yaml.ScalarNode:
+			addText(parent, scalar(value))
+		case attribute && strings.HasPrefix(key, m.options.AttributePrefix):
+			addAttribute(parent, key[len(m.options.AttributePrefix):], scalar(value))
+		case attribute && m.options.Scalars == MapAttribute:
+			addAttribute(parent, key, scalar(value))
+		default:
+			m.element(parent, key, value)
+		}
+	}
+}
+
+// merge adds the keys of mappings, that are merged into another with "<<: *name" in YAML.
+func (m *objectMapper) merge(parent *Node, value *yaml.Node) {
+	switch value.Kind {
+	case yaml.MappingNode:
+		m.fields(parent, value)
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			m.merge(parent, resolveAlias(item))
+		}
+	}
+}
+
+// element adds an element for a value with a key to its parent.
+func (m *objectMapper) element(parent *Node, name string, value *yaml.Node) {
+	value = resolveAlias(value)
+
+	if value.Kind == yaml.SequenceNode && m.options.Arrays == MapRepeat {
+		for _, item := range value.Content {
+			// Arrays in arrays cannot be repeated, they are wrapped instead.
+			if resolveAlias(item).Kind == yaml.SequenceNode {
+				m.items(addElement(parent, name), item)
+			} else {
+				m.element(parent, name, item)
+			}
+		}
+
+		return
+	}
+
+	node := addElement(parent, name)
+
+	switch value.Kind {
+	case yaml.SequenceNode:
+		m.items(node, value)
+	case yaml.MappingNode:
+		m.fields(node, value)
+	case yaml.ScalarNode:
+		addText(node, scalar(value))
+	}
+}
+
+// items adds an element for each item of an array.
+func (m *objectMapper) items(parent *Node, sequence *yaml.Node) {
+	for _, item := range resolveAlias(sequence).Content {
+		m.element(parent, m.options.ItemName, item)
+	}
+}
+
+// resolveAlias returns the value an alias like *name stands for.
+func resolveAlias(value *yaml.Node) *yaml.Node {
+	for value.Kind == yaml.AliasNode && value.Alias != nil {
+		value = value.Alias
+	}
+
+	return value
+}
+
+// scalar returns a scalar as it is written, null is an empty string.
+func scalar(value *yaml.Node) string {
+	if value.Tag == "!!null" {
+		return ""
+	}
+
+	return value.Value
+}
+
+func addElement(parent *Node, name string) *Node {
+	node := &Node{Name: dymlName(name), Parent: parent}
+	parent.Children = append(parent.Children, node)
+
+	return node
+}
+
+func addAttribute(parent *Node, key, value string) {
+	parent.Attributes = append(parent.Attributes, &Attribute{Key: dymlName(key), Value: value})
+}
+
+func addText(parent *Node, text string) {
+	if text != "" {
+		parent.Children = append(parent.Children, &Node{Text: &text, Parent: parent})
+	}
+}
+
+// yamlErrorLine finds the line in errors of the YAML parser, like "yaml: line 3: did not find expected key".
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseObjects reads JSON or YAML into nodes, YAML may contain several documents. On errors,
+// all documents up to the error are returned.
+func parseObjects(src, format string, options ImportOptions) ([]*Node, error) {
+	root := &Node{Name: "root"}
+	m := &objectMapper{options: options.withDefaults()}
+
+	// JSON is read with the YAML parser, which keeps the order of keys, but checked to be JSON first.
+	if format == FormatJSON {
+		var value interface{}
+		if err := json.Unmarshal([]byte(src), &value); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, &lineError{line: strings.Count(src[:syntaxErr.Offset], "\n") + 1, msg: syntaxErr.Error()}
+			}
+
+			return nil, &lineError{line: 1, msg: err.Error()}
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(src)))
+
+	for {
+		var doc yaml.Node
+
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+				line, _ := strconv.Atoi(match[1])
+
+				return root.Children, &lineError{line: line, msg: match[2]}
+			}
+
+			return root.Children, &lineError{line: 1, msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+		}
+
+		m.add(root, &doc)
+	}
+
+	return root.Children, nil
+}
+
+// ConvertObjects converts JSON or YAML, as given by the format, to DYML. On errors, the DYML
+// contains everything up to the error.
+func ConvertObjects(src, format string, options ImportOptions) (string, error) {
+	if err := options.check(); err != nil {
+		return "", err
+	}
+
+	nodes, err := parseObjects(src, format, options)
+	// JSON and YAML have no comments, so none can be dropped.
+	dyml, _ := writeDYML(nodes, options.Grammar)
+
+	return dyml, err
+}
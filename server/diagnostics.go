@@ -0,0 +1,67 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diagnosticsDoc is the documentation of all diagnostic codes, relative to the root of the extension.
+const diagnosticsDoc = "docs/diagnostics.md"
+
+// diagnosticsDocURL is where the documentation is found online, should it not be bundled with the server.
+const diagnosticsDocURL = "https://github.com/golangee/dyml-vscode/blob/main/docs/diagnostics.md"
+
+// diagnosticsDocBase is the documentation that code descriptions link to.
+var diagnosticsDocBase = findDiagnosticsDoc()
+
+// findDiagnosticsDoc returns the URI of the bundled documentation. The server is located in out/bin
+// of the extension, from where we look for the documentation.
+func findDiagnosticsDoc() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return diagnosticsDocURL
+	}
+
+	path := filepath.Join(filepath.Dir(exe), "..", "..", filepath.FromSlash(diagnosticsDoc))
+	if _, err := os.Stat(path); err != nil {
+		return diagnosticsDocURL
+	}
+
+	return string(pathToURI(path))
+}
+
+// codeDescription links a diagnostic code to its documentation.
+func codeDescription(code string) *protocol.CodeDescription {
+	return &protocol.CodeDescription{
+		Href: protocol.URI(diagnosticsDocBase + "#" + code),
+	}
+}
+
+// parseErrorCode finds the code for an error of the lexer or parser. They do not have codes themselves,
+// so we recognize them by their messages.
+func parseErrorCode(message string) string {
+	switch {
+	case strings.Contains(message, "attribute already defined"), strings.Contains(message, "attribute defined multiple times"):
+		return "attribute-redefined"
+	case strings.Contains(message, "forwarded node cannot be forwarded anywhere"):
+		return "dangling-forward-element"
+	case strings.Contains(message, "forwarded attribute cannot be forwarded anywhere"):
+		return "dangling-forward-attribute"
+	case strings.Contains(message, "may not be escaped"):
+		return "invalid-escape"
+	case strings.Contains(message, "unable to read next rune"):
+		return "unexpected-end"
+	case strings.Contains(message, "invalid unicode sequence"):
+		return "invalid-encoding"
+	case strings.Contains(message, "unexpected char"):
+		return "unexpected-character"
+	case strings.Contains(message, "unexpected "):
+		return "unexpected-token"
+	case strings.Contains(message, "expected "), strings.Contains(message, "must be enclosed in"):
+		return "expected-token"
+	default:
+		return "syntax-error"
+	}
+}
@@ -0,0 +1,605 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/golangee/dyml/parser"
+	"github.com/golangee/dyml/token"
+)
+
+// Node is an element, text or comment in a parsed document.
+// In contrast to parser.TreeNode it knows where its name, attributes and block are located,
+// which we need to answer requests for a position in the editor.
+type Node struct {
+	// Name is the name of an element. It is empty for text and comment nodes.
+	Name string
+	// Text is set for text nodes.
+	Text *string
+	// Comment is set for comment nodes.
+	Comment    *string
+	Attributes []*Attribute
+	Children   []*Node
+	// Parent is nil for the root node.
+	Parent *Node
+	// BlockType describes the brackets surrounding the children.
+	BlockType parser.BlockType
+	// G2 is true when this element was defined in G2 syntax, i.e. without a leading '#'.
+	G2 bool
+	// Forwarded is true when this node was forwarded with '##' into its parent.
+	Forwarded bool
+	// NameRange is the range of the element's name. For text and comments it covers the text.
+	NameRange protocol.Range
+	// BlockRange spans the brackets around the children, including the brackets themselves.
+	// It is nil when the element has no brackets.
+	BlockRange *protocol.Range
+	// Range spans the whole node.
+	Range protocol.Range
+}
+
+// Attribute is an attribute of an element.
+type Attribute struct {
+	Key   string
+	Value string
+	// KeyRange is the range of the key without the leading '@'.
+	KeyRange protocol.Range
+	// ValueRange is the range of the value. It includes the quotes of G2 attributes.
+	ValueRange protocol.Range
+	// Range spans the whole attribute, from the '@' to the end of the value.
+	Range protocol.Range
+	// Forwarded is true when this attribute was forwarded with '@@' to this element.
+	Forwarded bool
+}
+
+// IsElement returns true if this node is neither text nor a comment.
+func (n *Node) IsElement() bool {
+	return n.Text == nil && n.Comment == nil
+}
+
+// IsRoot returns true for the implicit root element of a document.
+func (n *Node) IsRoot() bool {
+	return n.Parent == nil
+}
+
+// Path returns the names of all elements from the top of the document down to this element,
+// separated by '/'. The implicit root element has an empty path.
+func (n *Node) Path() string {
+	var names []string
+	for it := n; it != nil && !it.IsRoot(); it = it.Parent {
+		names = append([]string{it.Name}, names...)
+	}
+
+	return strings.Join(names, "/")
+}
+
+// Elements returns all children that are elements.
+func (n *Node) Elements() []*Node {
+	var elements []*Node
+	for _, child := range n.Children {
+		if child.IsElement() {
+			elements = append(elements, child)
+		}
+	}
+
+	return elements
+}
+
+// Attribute returns the attribute with the given key or nil.
+func (n *Node) Attribute(key string) *Attribute {
+	for _, attr := range n.Attributes {
+		if attr.Key == key {
+			return attr
+		}
+	}
+
+	return nil
+}
+
+// Walk calls fn for this node and all its descendants in document order.
+// When fn returns false the children of that node are skipped.
+func (n *Node) Walk(fn func(node *Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Document is the parsed form of a File.
+type Document struct {
+	File
+	// Root is the implicit root element. When parsing failed it contains everything
+	// that was parsed up to the error.
+	Root *Node
+	// Tokens contains all tokens the lexer could read.
+	Tokens []token.Token
+	// Err is the error that stopped the parser, or nil.
+	Err error
+	// lines are the lines of the content, used to convert between positions and offsets.
+	lines []string
+}
+
+// ParseDocument parses a file into a Document. Parsing never fails completely,
+// errors are recorded in the Document and the tree is built as far as possible.
+func ParseDocument(file File) *Document {
+	doc := &Document{
+		File:  file,
+		lines: strings.Split(file.Content, "\n"),
+	}
+
+	fileName := filepath.Base(string(file.Uri))
+
+	lexer := token.NewLexer(fileName, strings.NewReader(file.Content))
+	for {
+		tok, err := lexer.Token()
+		if err != nil {
+			break
+		}
+
+		doc.Tokens = append(doc.Tokens, tok)
+	}
+
+	builder := newTreeBuilder(doc)
+	visitor := parser.NewVisitor(fileName, strings.NewReader(file.Content))
+	visitor.SetVisitable(builder)
+
+	if err := visitor.Run(); err != nil && !errors.Is(err, io.EOF) {
+		doc.Err = err
+	} else {
+		// The parser stops at a stray closing bracket without an error, leaving out the rest of the document.
+		doc.Err = strayBracket(doc.Tokens)
+	}
+
+	doc.Root = builder.finish()
+
+	return doc
+}
+
+// End returns the position after the last character of the document.
+func (d *Document) End() protocol.Position {
+	last := len(d.lines) - 1
+
+	return protocol.Position{
+		Line:      uint32(last),
+		Character: uint32(len([]rune(d.lines[last]))),
+	}
+}
+
+// Offset converts a position to a byte offset into the content.
+// Positions outside the document are clamped to its bounds.
+func (d *Document) Offset(pos protocol.Position) int {
+	offset := 0
+
+	for i, line := range d.lines {
+		if uint32(i) == pos.Line {
+			runes := []rune(line)
+			if int(pos.Character) < len(runes) {
+				return offset + len(string(runes[:pos.Character]))
+			}
+
+			return offset + len(line)
+		}
+
+		// Add one for the newline.
+		offset += len(line) + 1
+	}
+
+	return len(d.Content)
+}
+
+// NodeAt returns the innermost element, whose name contains the position, or nil.
+func (d *Document) NodeAt(pos protocol.Position) *Node {
+	var found *Node
+
+	d.Root.Walk(func(node *Node) bool {
+		if node.IsElement() && !node.IsRoot() && rangeContains(node.NameRange, pos) {
+			found = node
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// AttributeAt returns the attribute, whose key contains the position, and the element it belongs to.
+func (d *Document) AttributeAt(pos protocol.Position) (*Node, *Attribute) {
+	var (
+		foundNode *Node
+		foundAttr *Attribute
+	)
+
+	d.Root.Walk(func(node *Node) bool {
+		for _, attr := range node.Attributes {
+			if rangeContains(attr.KeyRange, pos) {
+				foundNode, foundAttr = node, attr
+			}
+		}
+
+		return true
+	})
+
+	return foundNode, foundAttr
+}
+
+// ContainerAt returns the innermost element whose block surrounds the position.
+// This is the root element if the position is not inside any brackets.
+func (d *Document) ContainerAt(pos protocol.Position) *Node {
+	container := d.Root
+
+	d.Root.Walk(func(node *Node) bool {
+		if node.BlockRange == nil || !positionBefore(node.BlockRange.Start, pos) {
+			return true
+		}
+
+		// Blocks that are never closed end with the document and include its last position.
+		if positionBefore(pos, node.BlockRange.End) || (pos == node.BlockRange.End && !d.closedAt(pos)) {
+			container = node
+		}
+
+		return true
+	})
+
+	return container
+}
+
+// closedAt returns true if a closing bracket ends right at the position.
+func (d *Document) closedAt(pos protocol.Position) bool {
+	for _, tok := range d.Tokens {
+		switch tok.Type() {
+		case token.TokenBlockEnd, token.TokenGroupEnd, token.TokenGenericEnd:
+			if toPosition(tok.Pos().End()) == pos {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// treeBuilder is a parser.Visitable that builds a tree of Nodes, keeping track of positions.
+// Forwarded nodes and attributes are handled the same way the dyml parser does.
+type treeBuilder struct {
+	doc *Document
+	// stack holds all currently open elements.
+	stack []*Node
+	root  *Node
+	// lastOffset is the end offset of the last token that was passed to us.
+	lastOffset int
+	// forwardedNodes and forwardedAttributes will be placed in the next opened element.
+	forwardedNodes      []*Node
+	forwardedAttributes []*Attribute
+	// tokenIndex maps the offset of a token to its index in doc.Tokens.
+	tokenIndex map[int]int
+	// namedReturns tells for each open return arrow, if it opened an additional named element.
+	namedReturns []bool
+}
+
+func newTreeBuilder(doc *Document) *treeBuilder {
+	b := &treeBuilder{
+		doc:        doc,
+		tokenIndex: make(map[int]int),
+	}
+
+	for i, tok := range doc.Tokens {
+		b.tokenIndex[tok.Pos().Begin().Offset] = i
+	}
+
+	return b
+}
+
+// finish closes all elements that are still open, which happens when parsing failed,
+// and returns the root.
+func (b *treeBuilder) finish() *Node {
+	for len(b.stack) > 0 {
+		_ = b.Close()
+	}
+
+	if b.root == nil {
+		b.root = &Node{Name: "root"}
+	}
+
+	b.root.Range = protocol.Range{End: b.doc.End()}
+
+	return b.root
+}
+
+func (b *treeBuilder) top() *Node {
+	if len(b.stack) == 0 {
+		return nil
+	}
+
+	return b.stack[len(b.stack)-1]
+}
+
+func (b *treeBuilder) push(node *Node) {
+	node.Parent = b.top()
+	b.stack = append(b.stack, node)
+}
+
+// openNode opens a new element for the given name token, which might be nil for
+// elements that do not appear in the source.
+func (b *treeBuilder) openNode(name string, tok *token.Identifier, forwarded bool) {
+	node := &Node{
+		Name:      name,
+		Forwarded: forwarded,
+	}
+
+	if len(b.stack) == 0 {
+		// The first element is the implicit root which has no position.
+		b.root = node
+		b.push(node)
+
+		return
+	}
+
+	if tok != nil {
+		node.NameRange = toRange(tok.Position)
+		node.Range = node.NameRange
+		b.lastOffset = tok.End().Offset
+
+		// G1 elements are introduced by a '#' ('##' for forwarded ones).
+		if i, ok := b.tokenIndex[tok.Begin().Offset]; ok && i > 0 && b.doc.Tokens[i-1].Type() == token.TokenDefineElement {
+			node.Range.Start = toPosition(b.doc.Tokens[i-1].Pos().Begin())
+		} else {
+			node.G2 = true
+		}
+	} else {
+		// Elements without a name token come from return arrows, which only exist in G2.
+		node.G2 = true
+	}
+
+	if !forwarded {
+		node.Attributes = append(node.Attributes, b.forwardedAttributes...)
+		b.forwardedAttributes = nil
+
+		for _, child := range b.forwardedNodes {
+			child.Parent = node
+		}
+
+		node.Children = append(node.Children, b.forwardedNodes...)
+		b.forwardedNodes = nil
+	}
+
+	b.push(node)
+}
+
+func (b *treeBuilder) Open(name token.Identifier) error {
+	b.openNode(name.Value, &name, false)
+
+	return nil
+}
+
+func (b *treeBuilder) Comment(comment token.CharData) error {
+	b.addChild(&Node{
+		Comment:   &comment.Value,
+		NameRange: toRange(comment.Position),
+		Range:     toRange(comment.Position),
+	}, comment)
+
+	return nil
+}
+
+func (b *treeBuilder) Text(text token.CharData) error {
+	b.addChild(&Node{
+		Text:      &text.Value,
+		NameRange: toRange(text.Position),
+		Range:     toRange(text.Position),
+	}, text)
+
+	return nil
+}
+
+func (b *treeBuilder) addChild(node *Node, tok token.CharData) {
+	b.lastOffset = tok.End().Offset
+
+	if top := b.top(); top != nil {
+		node.Parent = top
+		top.Children = append(top.Children, node)
+	}
+}
+
+func (b *treeBuilder) OpenReturnArrow(arrow token.G2Arrow, name *token.Identifier) error {
+	b.lastOffset = arrow.End().Offset
+	b.openNode("ret", nil, false)
+	b.top().NameRange = toRange(arrow.Position)
+	b.top().Range = b.top().NameRange
+
+	if name != nil {
+		b.openNode(name.Value, name, false)
+	}
+
+	b.namedReturns = append(b.namedReturns, name != nil)
+
+	return nil
+}
+
+func (b *treeBuilder) CloseReturnArrow() error {
+	if len(b.namedReturns) > 0 {
+		named := b.namedReturns[len(b.namedReturns)-1]
+		b.namedReturns = b.namedReturns[:len(b.namedReturns)-1]
+
+		if named {
+			_ = b.Close()
+		}
+	}
+
+	return b.Close()
+}
+
+func (b *treeBuilder) SetBlockType(blockType parser.BlockType) error {
+	top := b.top()
+	if top == nil || top.IsRoot() || blockType == parser.BlockNone {
+		return nil
+	}
+
+	top.BlockType = blockType
+
+	// The visitor does not tell us about brackets, so we find the first opening bracket
+	// after the last token we have seen, and its matching closing bracket.
+	for i, tok := range b.doc.Tokens {
+		if tok.Pos().Begin().Offset < b.lastOffset || !isOpeningBracket(tok) {
+			continue
+		}
+
+		blockRange := protocol.Range{
+			Start: toPosition(tok.Pos().Begin()),
+			End:   b.doc.End(),
+		}
+
+		depth := 0
+
+		for _, next := range b.doc.Tokens[i:] {
+			if isOpeningBracket(next) {
+				depth++
+			} else if isClosingBracket(next) {
+				depth--
+			}
+
+			if depth == 0 {
+				blockRange.End = toPosition(next.Pos().End())
+
+				break
+			}
+		}
+
+		top.BlockRange = &blockRange
+		b.lastOffset = tok.Pos().End().Offset
+
+		break
+	}
+
+	return nil
+}
+
+func (b *treeBuilder) OpenForward(name token.Identifier) error {
+	b.openNode(name.Value, &name, true)
+
+	return nil
+}
+
+func (b *treeBuilder) TextForward(text token.CharData) error {
+	b.lastOffset = text.End().Offset
+	b.forwardedNodes = append(b.forwardedNodes, &Node{
+		Text:      &text.Value,
+		NameRange: toRange(text.Position),
+		Range:     toRange(text.Position),
+		Forwarded: true,
+	})
+
+	return nil
+}
+
+func (b *treeBuilder) Close() error {
+	if len(b.stack) == 0 {
+		return nil
+	}
+
+	node := b.top()
+	b.stack = b.stack[:len(b.stack)-1]
+
+	if node.IsRoot() {
+		return nil
+	}
+
+	// The node spans everything it contains.
+	if node.BlockRange != nil {
+		node.Range.End = maxPosition(node.Range.End, node.BlockRange.End)
+	}
+
+	for _, attr := range node.Attributes {
+		if !attr.Forwarded {
+			node.Range.End = maxPosition(node.Range.End, attr.Range.End)
+		}
+	}
+
+	for _, child := range node.Children {
+		if !child.Forwarded {
+			node.Range.End = maxPosition(node.Range.End, child.Range.End)
+		}
+	}
+
+	if node.Forwarded {
+		b.forwardedNodes = append(b.forwardedNodes, node)
+
+		return nil
+	}
+
+	if parent := b.top(); parent != nil {
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	return nil
+}
+
+func (b *treeBuilder) Attribute(key token.Identifier, value token.CharData) error {
+	b.lastOffset = value.End().Offset
+
+	if top := b.top(); top != nil {
+		top.Attributes = append(top.Attributes, b.newAttribute(key, value, false))
+	}
+
+	return nil
+}
+
+func (b *treeBuilder) AttributeForward(key token.Identifier, value token.CharData) error {
+	b.lastOffset = value.End().Offset
+	b.forwardedAttributes = append(b.forwardedAttributes, b.newAttribute(key, value, true))
+
+	return nil
+}
+
+func (b *treeBuilder) Finalize() error {
+	return nil
+}
+
+func (b *treeBuilder) newAttribute(key token.Identifier, value token.CharData, forwarded bool) *Attribute {
+	attr := &Attribute{
+		Key:        key.Value,
+		Value:      value.Value,
+		KeyRange:   toRange(key.Position),
+		ValueRange: toRange(value.Position),
+		Range:      protocol.Range{End: toPosition(value.End())},
+		Forwarded:  forwarded,
+	}
+	attr.Range.Start = attr.KeyRange.Start
+
+	// Extend the range to the '@' before the key and the '}' after a G1 value.
+	if i, ok := b.tokenIndex[key.Begin().Offset]; ok {
+		tokens := b.doc.Tokens
+
+		if i > 0 && tokens[i-1].Type() == token.TokenDefineAttribute {
+			attr.Range.Start = toPosition(tokens[i-1].Pos().Begin())
+		}
+
+		if i+3 < len(tokens) && tokens[i+1].Type() == token.TokenBlockStart && tokens[i+3].Type() == token.TokenBlockEnd {
+			attr.Range.End = toPosition(tokens[i+3].Pos().End())
+		}
+	}
+
+	return attr
+}
+
+func isOpeningBracket(tok token.Token) bool {
+	switch tok.Type() {
+	case token.TokenBlockStart, token.TokenGroupStart, token.TokenGenericStart:
+		return true
+	default:
+		return false
+	}
+}
+
+func isClosingBracket(tok token.Token) bool {
+	switch tok.Type() {
+	case token.TokenBlockEnd, token.TokenGroupEnd, token.TokenGenericEnd:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,244 @@
+package dyml
+
+import (
+	"strings"
+)
+
+// Grammars that DYML is generated in.
+const (
+	GrammarG1 = "g1"
+	GrammarG2 = "g2"
+)
+
+var (
+	// g1TextEscaper escapes text in G1, which ends at '#' or '}'.
+	g1TextEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`, "}", `\}`)
+	// g1CommentEscaper escapes comments in G1, which end at '#'. Nothing else may be escaped in them.
+	g1CommentEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`)
+	// g1ValueEscaper escapes attribute values in G1, which end at '}'.
+	g1ValueEscaper = strings.NewReplacer(`\`, `\\`, "}", `\}`)
+	// g2Escaper escapes quoted strings in G2.
+	g2Escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// dymlName turns a name from another format into a valid name of an element or attribute.
+// Namespace prefixes like "svg:rect" become "svg.rect" and other characters that are not allowed become '_'.
+func dymlName(name string) string {
+	parts := strings.Split(strings.ReplaceAll(name, ":", "."), ".")
+
+	for i, part := range parts {
+		part = strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+				return r
+			}
+
+			return '_'
+		}, part)
+
+		if part == "" {
+			part = "_"
+		}
+
+		parts[i] = part
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// dymlWriter writes a tree of nodes, which does not come from a parsed document, as DYML.
+// Elements are written in the style of the grammar, with text only elements in a single line.
+type dymlWriter struct {
+	sb strings.Builder
+	g2 bool
+	// dropped are the comments that could not be written.
+	dropped []*Node
+}
+
+// lines returns text or a comment without whitespace at the start and end of its lines.
+func lines(text string) []string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return lines
+}
+
+// onlyText returns the text of an element, if it contains a single line of text and nothing else.
+func onlyText(node *Node) (string, bool) {
+	if len(node.Children) != 1 || node.Children[0].Text == nil {
+		return "", false
+	}
+
+	text := strings.TrimSpace(*node.Children[0].Text)
+
+	return text, !strings.Contains(text, "\n")
+}
+
+// g1Nodes writes nodes in G1, or the top level of a G2 document, where each element starts with "#!".
+func (w *dymlWriter) g1Nodes(nodes []*Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	ordered, dropped := g1Order(nodes, depth)
+	w.dropped = append(w.dropped, dropped...)
+
+	for _, node := range ordered {
+		switch {
+		case node.Comment != nil:
+			w.sb.WriteString(indent + "#? " + strings.Join(lines(g1CommentEscaper.Replace(*node.Comment)), "\n"+indent) + "\n")
+		case node.Text != nil:
+			w.sb.WriteString(indent + strings.Join(lines(g1TextEscaper.Replace(*node.Text)), "\n"+indent) + "\n")
+		case w.g2 && depth == 0:
+			w.sb.WriteString("#! ")
+			w.g2Element(node, 0, true)
+		default:
+			w.g1Element(node, depth)
+		}
+	}
+}
+
+// g1Order moves comments to where G1 can keep them. A comment goes on until the next element or comment starts,
+// so it would swallow text after it or the bracket that closes its parent. Comments before text are moved
+// in front of the next element, those at the end of a block in front of its last element. At the top level,
+// the end of the document ends them. Comments in blocks without elements are returned as dropped.
+func g1Order(nodes []*Node, depth int) (ordered, dropped []*Node) {
+	ordered = make([]*Node, 0, len(nodes))
+	// pending are the comments waiting for the next element.
+	var pending []*Node
+
+	for _, node := range nodes {
+		switch {
+		case node.Comment != nil:
+			pending = append(pending, node)
+		case node.IsElement():
+			ordered = append(append(ordered, pending...), node)
+			pending = nil
+		default:
+			ordered = append(ordered, node)
+		}
+	}
+
+	if len(pending) == 0 || depth == 0 {
+		return append(ordered, pending...), nil
+	}
+
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if ordered[i].IsElement() {
+			return append(ordered[:i], append(pending, ordered[i:]...)...), nil
+		}
+	}
+
+	return ordered, pending
+}
+
+// g1Element writes an element in G1. Elements always get brackets, so that text after them is not taken as theirs.
+func (w *dymlWriter) g1Element(node *Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	w.sb.WriteString(indent + "#" + node.Name)
+
+	for _, attr := range node.Attributes {
+		w.sb.WriteString(" @" + attr.Key + "{" + g1ValueEscaper.Replace(attr.Value) + "}")
+	}
+
+	if text, ok := onlyText(node); ok {
+		w.sb.WriteString(" {" + g1TextEscaper.Replace(text) + "}\n")
+
+		return
+	}
+
+	if len(node.Children) == 0 {
+		w.sb.WriteString(" {}\n")
+
+		return
+	}
+
+	w.sb.WriteString(" {\n")
+	w.g1Nodes(node.Children, depth+1)
+	w.sb.WriteString(indent + "}\n")
+}
+
+// g2Nodes writes the children of an element in G2.
+func (w *dymlWriter) g2Nodes(nodes []*Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	for _, node := range g2Order(nodes) {
+		switch {
+		case node.Comment != nil:
+			for _, line := range lines(*node.Comment) {
+				w.sb.WriteString(indent + "// " + line + "\n")
+			}
+		case node.Text != nil:
+			w.sb.WriteString(indent + `"` + strings.Join(lines(g2Escaper.Replace(*node.Text)), "\n"+indent) + `",` + "\n")
+		default:
+			w.sb.WriteString(indent)
+			w.g2Element(node, depth, false)
+		}
+	}
+}
+
+// g2Order moves comments that follow an element in front of the elements before them, up to the previous text
+// or the start of the block. The parser takes comments after an element as its last children, so comments
+// only stay where they are at the start of a block or after text.
+func g2Order(nodes []*Node) []*Node {
+	ordered := make([]*Node, 0, len(nodes))
+	// elementsStart is where the elements since the last text or comment start.
+	elementsStart := 0
+
+	for _, node := range nodes {
+		switch {
+		case node.IsElement():
+			ordered = append(ordered, node)
+
+			continue
+		case node.Comment != nil && elementsStart < len(ordered):
+			ordered = append(ordered[:elementsStart+1], ordered[elementsStart:]...)
+			ordered[elementsStart] = node
+		default:
+			ordered = append(ordered, node)
+			elementsStart = len(ordered)
+
+			continue
+		}
+
+		elementsStart++
+	}
+
+	return ordered
+}
+
+// g2Element writes an element in G2, after the indentation. Elements without brackets are followed by a comma,
+// so that the next element is not nested inside. Top level elements must have brackets.
+func (w *dymlWriter) g2Element(node *Node, depth int, top bool) {
+	w.sb.WriteString(node.Name)
+
+	for _, attr := range node.Attributes {
+		w.sb.WriteString(" @" + attr.Key + `="` + g2Escaper.Replace(attr.Value) + `"`)
+	}
+
+	text, ok := onlyText(node)
+
+	switch {
+	case ok && top:
+		w.sb.WriteString(` {"` + g2Escaper.Replace(text) + `"}` + "\n")
+	case ok:
+		w.sb.WriteString(` "` + g2Escaper.Replace(text) + `",` + "\n")
+	case len(node.Children) == 0 && top:
+		w.sb.WriteString(" {}\n")
+	case len(node.Children) == 0:
+		w.sb.WriteString(",\n")
+	default:
+		w.sb.WriteString(" {\n")
+		w.g2Nodes(node.Children, depth+1)
+		w.sb.WriteString(strings.Repeat("    ", depth) + "}\n")
+	}
+}
+
+// writeDYML returns the nodes as DYML in the given grammar, which is G1 unless it is GrammarG2,
+// along with the comments that could not be written.
+func writeDYML(nodes []*Node, grammar string) (string, []*Node) {
+	w := &dymlWriter{g2: grammar == GrammarG2}
+	w.g1Nodes(nodes, 0)
+
+	return w.sb.String(), w.dropped
+}
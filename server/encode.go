@@ -0,0 +1,172 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+)
+
+// EncodeXMLParams are the parameters of custom/encodeXML.
+type EncodeXMLParams struct {
+	// TextDocument is the document to encode. Its version is the one the client has,
+	// which may be left out for documents that are not open.
+	TextDocument protocol.OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+	// Options replace the xml settings, if they are given.
+	Options *XMLOptions `json:"options,omitempty"`
+	// Range is a selection, to only encode the elements in it. An empty range selects the element around the cursor.
+	Range *protocol.Range `json:"range,omitempty"`
+}
+
+// EncodeXMLResult is the result of custom/encodeXML.
+type EncodeXMLResult struct {
+	// XML is the encoded document. When there are errors, it contains everything up to the first one.
+	XML string `json:"xml"`
+	// Errors are the syntax errors of the document.
+	Errors []protocol.Diagnostic `json:"errors"`
+	// Success is true if the document was encoded without errors.
+	Success bool `json:"success"`
+	// Version is the version of the document the XML was computed from. It is 0 for documents that are not open.
+	// A client can tell from it, if the document changed in the meantime.
+	Version int32 `json:"version"`
+	// SourceMap maps the parts of the XML to where they come from.
+	SourceMap []SourceMapping `json:"sourceMap"`
+}
+
+// Formats of custom/encode.
+const (
+	FormatXML  = "xml"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// EncodeParams are the parameters of custom/encode. They are those of custom/encodeXML and a format.
+// For JSON and YAML only the options expandIncludes, indent and minify are used.
+type EncodeParams struct {
+	EncodeXMLParams
+	// Format is one of "xml", "json" or "yaml".
+	Format string `json:"format"`
+}
+
+// EncodeResult is the result of custom/encode.
+type EncodeResult struct {
+	// Output is the encoded document. When there are errors, it contains everything up to the first one.
+	Output string `json:"output"`
+	// Errors are the syntax errors of the document, or why it could not be encoded at all.
+	Errors []protocol.Diagnostic `json:"errors"`
+	// Success is true if the document was encoded without errors.
+	Success bool `json:"success"`
+	// Version is the version of the document the output was computed from. It is 0 for documents that are not open.
+	Version int32 `json:"version"`
+	// SourceMap maps the parts of XML to where they come from. It is only set for XML.
+	SourceMap []SourceMapping `json:"sourceMap,omitempty"`
+}
+
+// Encode encodes a document, or the selected part of it, as XML, JSON or YAML with the options of the request
+// or the xml settings. Documents with syntax errors are encoded as far as they could be parsed.
+func (s *Server) Encode(params *EncodeParams) EncodeResult {
+	failed := func(err string) EncodeResult {
+		return EncodeResult{Errors: []protocol.Diagnostic{{Severity: protocol.SeverityError, Message: err}}}
+	}
+
+	switch params.Format {
+	case FormatXML, FormatJSON, FormatYAML:
+	default:
+		return failed(fmt.Sprintf("unknown format '%s', expected xml, json or yaml", params.Format))
+	}
+
+	uri := params.TextDocument.URI
+
+	file, ok := s.files[uri]
+	if !ok {
+		content, err := s.readFile(uri)
+		if err != nil {
+			return failed(err.Error())
+		}
+
+		file = File{Uri: uri, Content: content}
+	}
+
+	doc := ParseDocument(file)
+	errors := diagnosticsOf(syntaxProblems(doc))
+
+	options := s.config.XML
+	if params.Options != nil {
+		options = *params.Options
+	}
+
+	nodes := doc.Root.Children
+	if params.Range != nil {
+		nodes = selectedNodes(doc, *params.Range)
+	}
+
+	var (
+		output    string
+		sourceMap []SourceMapping
+	)
+
+	switch params.Format {
+	case FormatXML:
+		output, sourceMap = writeXML(doc, nodes, options, s.resolveInclude)
+	case FormatJSON:
+		output = writeJSON(buildJSON(doc, nodes, options, s.resolveInclude), options)
+	case FormatYAML:
+		output = writeYAML(buildJSON(doc, nodes, options, s.resolveInclude), options)
+	}
+
+	return EncodeResult{
+		Output:    output,
+		Errors:    errors,
+		Success:   len(errors) == 0,
+		Version:   file.Version,
+		SourceMap: sourceMap,
+	}
+}
+
+// EncodeXML encodes a document as XML, it is custom/encode for the format "xml".
+func (s *Server) EncodeXML(params *EncodeXMLParams) EncodeXMLResult {
+	result := s.Encode(&EncodeParams{EncodeXMLParams: *params, Format: FormatXML})
+
+	return EncodeXMLResult{
+		XML:       result.Output,
+		Errors:    result.Errors,
+		Success:   result.Success,
+		Version:   result.Version,
+		SourceMap: result.SourceMap,
+	}
+}
+
+// selectedNodes returns the nodes in a selection. An empty selection selects the innermost element around it,
+// otherwise all nodes that overlap the selection, inside the innermost element that contains all of it.
+func selectedNodes(doc *Document, selection protocol.Range) []*Node {
+	if selection.Start == selection.End {
+		var found *Node
+
+		doc.Root.Walk(func(node *Node) bool {
+			if node.IsElement() && !node.IsRoot() && rangeContains(node.Range, selection.Start) {
+				found = node
+			}
+
+			return true
+		})
+
+		if found == nil {
+			return doc.Root.Children
+		}
+
+		return []*Node{found}
+	}
+
+	container := doc.ContainerAt(selection.Start)
+	for !container.IsRoot() && (container.BlockRange == nil || positionBefore(container.BlockRange.End, selection.End)) {
+		container = container.Parent
+	}
+
+	var nodes []*Node
+
+	for _, child := range container.Children {
+		if positionBefore(selection.Start, child.Range.End) && positionBefore(child.Range.Start, selection.End) {
+			nodes = append(nodes, child)
+		}
+	}
+
+	return nodes
+}
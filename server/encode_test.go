@@ -0,0 +1,97 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update writes the output of tests to their golden files, instead of comparing it.
+var update = flag.Bool("update", false, "update golden files")
+
+// golden compares output with the golden file at path.
+func golden(t *testing.T, path, output string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output != string(want) {
+		t.Errorf("output differs from %s, got:\n%s", path, output)
+	}
+}
+
+// TestEncodeGolden encodes each document in testdata/encode to every format and compares the output
+// with the file of the same name and the format as extension. Run with -update to write them.
+func TestEncodeGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "encode", "*.dyml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		uri := protocol.DocumentURI("file:///" + filepath.Base(path))
+		s := NewServer()
+		s.files[uri] = File{Uri: uri, Content: string(content), Version: 1}
+
+		for _, format := range []string{FormatXML, FormatJSON, FormatYAML} {
+			name := strings.TrimSuffix(path, ".dyml") + "." + format
+
+			t.Run(filepath.Base(name), func(t *testing.T) {
+				params := &EncodeParams{Format: format}
+				params.TextDocument.URI = uri
+
+				result := s.Encode(params)
+				if !result.Success {
+					t.Fatalf("encoding failed: %v", result.Errors)
+				}
+
+				golden(t, name, result.Output)
+			})
+		}
+	}
+}
+
+func TestEncodeXMLText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		options XMLOptions
+		want    string
+	}{
+		{"minified words", "#p {a #b {} c}\n", XMLOptions{Minify: true}, "<root><p>a <b></b> c</p></root>"},
+		{"minified lines", "#p {\n    first\n    #b {}\n    second\n}\n", XMLOptions{Minify: true}, "<root><p>first <b></b> second</p></root>"},
+		{"minified joined", "#p {a#b {}c}\n", XMLOptions{Minify: true}, "<root><p>a<b></b>c</p></root>"},
+		{"minified g2", "#! p {\n    \"a \" b, \"c\"\n}\n", XMLOptions{Minify: true}, "<root><p>a <b></b>c</p></root>"},
+		{"comment", "#? a -- b --- c-\n#p {}\n", XMLOptions{}, "<root>\n    <!-- a - - b - - - c-\n -->\n    <p>\n    </p>\n</root>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ParseDocument(File{Uri: "file:///test.dyml", Content: tt.content})
+
+			got, _ := writeXML(doc, doc.Root.Children, tt.options, nil)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,8 @@
 module dyml-support
 
-go 1.17
+go 1.18
 
-require github.com/golangee/dyml v0.0.0-20211108095144-c6773f6e021b
+require (
+	github.com/golangee/dyml v0.0.0-20211108095144-c6773f6e021b
+	gopkg.in/yaml.v3 v3.0.1
+)
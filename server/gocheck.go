@@ -0,0 +1,392 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"strings"
+)
+
+// CheckGoType checks if a document would unmarshal into the Go type with dyml.Unmarshal and
+// returns everything that would fail or be ignored. Strict checks as for strict mode,
+// otherwise missing fields are only reported if they are not optional.
+func CheckGoType(doc *Document, t *GoType, strict bool) []Problem {
+	c := goChecker{strict: strict}
+	c.value(doc.Root, t)
+
+	return c.problems
+}
+
+// goChecker walks a document and a Go type side by side, following the rules of the unmarshaller.
+type goChecker struct {
+	validator
+	strict bool
+}
+
+// used records which children and attributes of an element were read by a field.
+type used struct {
+	nodes      map[*Node]bool
+	attributes map[*Attribute]bool
+	// all is set if the whole element was read by something we cannot look into.
+	all bool
+}
+
+// value checks a node against a type.
+func (c *goChecker) value(node *Node, t *GoType) {
+	switch t.Kind {
+	case GoOpaque:
+	case GoPointer:
+		c.value(node, t.Elem)
+	case GoPrimitive:
+		c.primitive(node, t)
+	case GoStruct:
+		if !node.IsElement() {
+			c.report("go-type-mismatch", protocol.SeverityError, node.Range, nil, "text cannot be unmarshalled into %s", t.Name)
+
+			return
+		}
+
+		u := used{nodes: make(map[*Node]bool), attributes: make(map[*Attribute]bool)}
+		c.fields(node, t, &u)
+		c.unused(node, t, &u)
+	case GoSlice:
+		elem := t.Elem
+		if elem.Kind == GoSlice {
+			// The unmarshaller flattens slices of slices.
+			elem = elem.Elem
+		}
+
+		for _, child := range node.Children {
+			if child.Comment == nil {
+				c.value(child, elem)
+			}
+		}
+	case GoMap:
+		c.mapValue(node, t)
+	case GoArray:
+		c.report("go-unsupported-type", protocol.SeverityError, nameRange(node), nil, "%s cannot be unmarshalled into %s, use a slice instead", describe(node), t.Name)
+	default:
+		c.report("go-unsupported-type", protocol.SeverityError, nameRange(node), nil, "%s cannot be unmarshalled into unsupported type %s", describe(node), t.Name)
+	}
+}
+
+// fields checks all fields of a struct against an element and records what they read.
+func (c *goChecker) fields(node *Node, t *GoType, u *used) {
+	for _, field := range t.Fields {
+		switch field.As {
+		case "":
+			c.elementField(node, field, u)
+		case "attr":
+			c.attributeField(node, field, u)
+		case "inner":
+			if inner := field.Type.deref(); inner.Kind == GoStruct {
+				c.fields(node, inner, u)
+
+				continue
+			}
+
+			if field.Type.deref().Kind == GoOpaque {
+				u.all = true
+			}
+
+			for _, child := range node.Children {
+				u.nodes[child] = true
+			}
+
+			c.value(node, field.Type)
+		default:
+			c.report("go-invalid-tag", protocol.SeverityError, nameRange(node), nil,
+				"%s cannot be unmarshalled into %s, field %s has the invalid dyml tag '%s'", describe(node), t.Name, field.Name, field.As)
+		}
+	}
+}
+
+// elementField checks a field that is read from child elements.
+func (c *goChecker) elementField(node *Node, field *GoField, u *used) {
+	// A renamed slice collects all children with that name.
+	if field.Type.Kind == GoSlice && field.Rename {
+		elem := field.Type.Elem
+		if elem.Kind == GoSlice {
+			elem = elem.Elem
+		}
+
+		for _, child := range node.Children {
+			if child.IsElement() && child.Name == field.Key {
+				u.nodes[child] = true
+				c.value(child, elem)
+			}
+		}
+
+		return
+	}
+
+	var matches []*Node
+
+	for _, child := range node.Children {
+		if child.IsElement() && child.Name == field.Key {
+			u.nodes[child] = true
+			matches = append(matches, child)
+		}
+	}
+
+	if len(matches) == 0 {
+		c.missing(node, field, nil, "element")
+
+		return
+	}
+
+	for _, duplicate := range matches[1:] {
+		if c.strict {
+			c.report("go-duplicate-field", protocol.SeverityError, duplicate.NameRange, nil, "'%s' is defined multiple times in %s", field.Key, describe(node))
+		} else {
+			d := c.report("go-duplicate-field", protocol.SeverityWarning, duplicate.NameRange, []Fix{removeFix(fmt.Sprintf("Remove element '%s'", field.Key), duplicate.Range)},
+				"'%s' is defined multiple times in %s, only the first one is unmarshalled", field.Key, describe(node))
+			d.Tags = []protocol.DiagnosticTag{protocol.Unnecessary}
+		}
+	}
+
+	c.value(matches[0], field.Type)
+}
+
+// attributeField checks a field that is read from an attribute.
+func (c *goChecker) attributeField(node *Node, field *GoField, u *used) {
+	attr := node.Attribute(field.Key)
+	if attr == nil {
+		c.missing(node, field, &AttributeSchema{Name: field.Key}, "attribute")
+
+		return
+	}
+
+	u.attributes[attr] = true
+
+	switch t := field.Type.deref(); t.Kind {
+	case GoOpaque:
+	case GoPrimitive:
+		if problem := t.CheckValue(attr.Value); problem != "" {
+			c.report("go-invalid-value", protocol.SeverityError, attr.ValueRange, nil, "%s, required by field %s", problem, field.Name)
+		}
+	default:
+		c.report("go-unsupported-type", protocol.SeverityError, attr.KeyRange, nil,
+			"attribute '%s' cannot be unmarshalled into field %s, attributes require a primitive type instead of %s", attr.Key, field.Name, field.Type.Name)
+	}
+}
+
+// missing reports a field that has nothing to be read from. attrSchema is set to offer adding an attribute,
+// which is impossible for the root.
+func (c *goChecker) missing(node *Node, field *GoField, attrSchema *AttributeSchema, what string) {
+	if !c.strict && field.Type.Optional() {
+		return
+	}
+
+	var fixes []Fix
+	if attrSchema != nil && !node.IsRoot() {
+		fixes = append(fixes, addAttributeFix(node, attrSchema))
+	}
+
+	if c.strict {
+		c.report("go-missing-field", protocol.SeverityError, nameRange(node), fixes, "%s is missing the %s '%s' required by field %s", describe(node), what, field.Key, field.Name)
+	} else {
+		c.report("go-missing-field", protocol.SeverityWarning, nameRange(node), fixes, "%s is missing the %s '%s', field %s is left empty", describe(node), what, field.Key, field.Name)
+	}
+}
+
+// unused reports the children and attributes of an element that no field reads.
+func (c *goChecker) unused(node *Node, t *GoType, u *used) {
+	if u.all {
+		return
+	}
+
+	for _, attr := range node.Attributes {
+		if !u.attributes[attr] {
+			c.report("go-unknown-field", protocol.SeverityError, attr.KeyRange, []Fix{removeFix(fmt.Sprintf("Remove attribute '%s'", attr.Key), attr.Range)},
+				"attribute '%s' does not match any field of %s", attr.Key, t.Name)
+		}
+	}
+
+	for _, child := range node.Children {
+		if child.IsElement() && !u.nodes[child] {
+			c.report("go-unknown-field", protocol.SeverityError, child.NameRange, []Fix{removeFix(fmt.Sprintf("Remove element '%s'", child.Name), child.Range)},
+				"element '%s' does not match any field of %s", child.Name, t.Name)
+		}
+	}
+}
+
+// primitive checks a node that is unmarshalled into a string, bool or number.
+func (c *goChecker) primitive(node *Node, t *GoType) {
+	if node.Text != nil {
+		if problem := t.CheckValue(*node.Text); problem != "" {
+			c.report("go-invalid-value", protocol.SeverityError, node.Range, nil, "%s", problem)
+		}
+
+		return
+	}
+
+	if t.Basic == "string" {
+		// Strings are the concatenation of all text children, elements are ignored.
+		texts := 0
+
+		for _, child := range node.Children {
+			switch {
+			case child.Text != nil:
+				texts++
+			case child.IsElement():
+				d := c.report("go-ignored-element", protocol.SeverityWarning, child.NameRange, []Fix{removeFix(fmt.Sprintf("Remove element '%s'", child.Name), child.Range)},
+					"element '%s' is ignored, %s is unmarshalled into a %s", child.Name, describe(node), t.Name)
+				d.Tags = []protocol.DiagnosticTag{protocol.Unnecessary}
+			}
+		}
+
+		if c.strict && texts != 1 {
+			c.report("go-type-mismatch", protocol.SeverityError, nameRange(node), nil, "%s must contain exactly one text to be unmarshalled into a %s", describe(node), t.Name)
+		}
+
+		return
+	}
+
+	text, problem := textOf(node)
+	if problem != "" {
+		c.report("go-type-mismatch", protocol.SeverityError, nameRange(node), nil, "%s cannot be unmarshalled into a %s, %s", describe(node), t.Name, problem)
+
+		return
+	}
+
+	if problem := t.CheckValue(text); problem != "" {
+		c.report("go-invalid-value", protocol.SeverityError, nameRange(node), nil, "%s", problem)
+	}
+}
+
+// mapValue checks an element whose children are unmarshalled as keys of a map, with their first child as value.
+func (c *goChecker) mapValue(node *Node, t *GoType) {
+	key := t.Key.deref()
+	if key.Kind != GoPrimitive {
+		c.report("go-unsupported-type", protocol.SeverityError, nameRange(node), nil, "%s cannot be unmarshalled into %s, map keys must have a primitive type", describe(node), t.Name)
+
+		return
+	}
+
+	for _, keyNode := range node.Children {
+		if keyNode.Comment != nil {
+			continue
+		}
+
+		if keyNode.Text != nil {
+			if c.strict {
+				c.report("go-type-mismatch", protocol.SeverityError, keyNode.Range, nil, "text cannot be a key of %s", t.Name)
+			}
+
+			continue
+		}
+
+		if problem := key.CheckValue(keyNode.Name); problem != "" {
+			c.report("go-invalid-value", protocol.SeverityError, keyNode.NameRange, nil, "%s, required as key of %s", problem, t.Name)
+		}
+
+		var values []*Node
+
+		for _, child := range keyNode.Children {
+			if child.Comment == nil {
+				values = append(values, child)
+			}
+		}
+
+		switch {
+		case len(values) == 0:
+			c.report("go-map-value", protocol.SeverityError, keyNode.NameRange, nil, "key '%s' has no value", keyNode.Name)
+
+			continue
+		case c.strict && len(values) > 1:
+			c.report("go-map-value", protocol.SeverityError, keyNode.NameRange, nil, "key '%s' needs exactly one value", keyNode.Name)
+		}
+
+		switch elem := t.Elem; elem.Kind {
+		case GoPrimitive:
+			value := values[0]
+			if value.Text != nil {
+				c.primitive(value, elem)
+
+				continue
+			}
+
+			if c.strict && len(value.Elements()) > 0 {
+				c.report("go-map-value", protocol.SeverityError, value.NameRange, nil, "value of key '%s' must not have children", keyNode.Name)
+			}
+
+			if problem := elem.CheckValue(value.Name); problem != "" {
+				c.report("go-invalid-value", protocol.SeverityError, value.NameRange, nil, "%s", problem)
+			}
+		default:
+			// Values that are no primitives are read from the key element itself.
+			c.value(keyNode, elem)
+		}
+	}
+}
+
+// textOf returns the text a number or bool is read from: the text itself, the name of an element
+// without children, or the text or name of its only child.
+func textOf(node *Node) (string, string) {
+	var children []*Node
+
+	for _, child := range node.Children {
+		if child.Comment == nil {
+			children = append(children, child)
+		}
+	}
+
+	switch {
+	case len(children) == 0:
+		return node.Name, ""
+	case len(children) > 1:
+		return "", "it has more than one child"
+	case children[0].Text != nil:
+		return *children[0].Text, ""
+	case !onlyComments(children[0]):
+		return "", "its child must not have children"
+	default:
+		return children[0].Name, ""
+	}
+}
+
+// onlyComments returns true if all children of a node are comments.
+func onlyComments(node *Node) bool {
+	for _, child := range node.Children {
+		if child.Comment == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameRange is where problems of a node are reported: its name for elements and everything for text.
+func nameRange(node *Node) protocol.Range {
+	if node.IsElement() {
+		return node.NameRange
+	}
+
+	return node.Range
+}
+
+// goTypeProblems checks a document against the Go type it is unmarshalled into, if it has one.
+// Failing to load the type is reported as a problem, too.
+func (s *Server) goTypeProblems(doc *Document) []Problem {
+	ref, ok, err := s.goTypeFor(doc)
+	if !ok {
+		return nil
+	}
+
+	var t *GoType
+	if err == nil {
+		t, err = LoadGoType(ref.dir, ref.name)
+	}
+
+	if err != nil {
+		return []Problem{{Diagnostic: protocol.Diagnostic{
+			Range:           ref.where,
+			Severity:        protocol.SeverityError,
+			Code:            "go-type-load-failed",
+			CodeDescription: codeDescription("go-type-load-failed"),
+			Message:         fmt.Sprintf("failed to load Go type %s: %s", ref.name, strings.TrimSpace(err.Error())),
+		}}}
+	}
+
+	return CheckGoType(doc, t, ref.strict)
+}
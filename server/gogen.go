@@ -0,0 +1,312 @@
+package dyml
+
+import (
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenerateGo writes Go types for a schema, together with a function that unmarshals documents into them.
+// The root of the schema becomes the type with the given name, elements with attributes or children
+// become structs, elements that may occur more than once become slices and attributes become typed fields.
+func GenerateGo(schema *Schema, pkg, typeName string) (string, error) {
+	g := goGenerator{names: make(map[string]bool)}
+
+	var sb strings.Builder
+
+	sb.WriteString("// Code generated by dyml gen-go. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&sb, "package %s\n\n", pkg)
+	sb.WriteString("import (\n\t\"io\"\n\n\t\"github.com/golangee/dyml\"\n)\n\n")
+	fmt.Fprintf(&sb, "// Parse%s unmarshals a DYML document.\n", typeName)
+	fmt.Fprintf(&sb, "func Parse%[1]s(r io.Reader) (*%[1]s, error) {\n", typeName)
+
+	if top := schema.Root.Children; len(top) == 1 && top[0].Min == 1 && top[0].Max == 1 && !isLeaf(top[0]) {
+		// Documents with a single element are unmarshalled into a wrapper, of which only the element is returned.
+		docType := uniqueName(typeName+"Document", g.names)
+		g.names[typeName] = true
+		g.decls = append(g.decls, fmt.Sprintf("type %s struct {\n\t%s %s `dyml:\"%s\"`\n}\n", docType, goName(top[0].Name), typeName, top[0].Name))
+		g.structType(top[0], typeName, false)
+
+		fmt.Fprintf(&sb, "\tvar v %s\n", docType)
+		sb.WriteString("\tif err := dyml.Unmarshal(r, &v, false); err != nil {\n\t\treturn nil, err\n\t}\n\n")
+		fmt.Fprintf(&sb, "\treturn &v.%s, nil\n}\n", goName(top[0].Name))
+	} else {
+		g.names[typeName] = true
+		g.structType(schema.Root, typeName, true)
+
+		fmt.Fprintf(&sb, "\tvar v %s\n", typeName)
+		sb.WriteString("\tif err := dyml.Unmarshal(r, &v, false); err != nil {\n\t\treturn nil, err\n\t}\n\n\treturn &v, nil\n}\n")
+	}
+
+	for _, decl := range g.decls {
+		sb.WriteString("\n" + decl)
+	}
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("generated invalid code: %w", err)
+	}
+
+	return string(src), nil
+}
+
+// goGenerator collects the declarations of all generated types.
+type goGenerator struct {
+	decls []string
+	// names are the type names in use.
+	names map[string]bool
+}
+
+// structType declares a struct for an element with the given type name.
+// The root gets no field for text, as text between the top level elements is rarely wanted.
+func (g *goGenerator) structType(element *ElementSchema, typeName string, root bool) {
+	var sb strings.Builder
+
+	// The declaration is reserved first, so that types of children follow their parent.
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+
+	writeGoDoc(&sb, "", typeName, element.Doc, element.Deprecated)
+	fmt.Fprintf(&sb, "type %s struct {\n", typeName)
+
+	fields := make(map[string]bool)
+
+	for _, attr := range element.Attributes {
+		fieldName := uniqueName(goName(attr.Name), fields)
+		writeGoDoc(&sb, "\t", fieldName, attr.Doc, attr.Deprecated)
+		fmt.Fprintf(&sb, "\t%s %s `dyml:\"%s,attr\"`\n", fieldName, attributeGoType(attr), attr.Name)
+	}
+
+	for _, child := range element.Children {
+		fieldName := uniqueName(goName(child.Name), fields)
+
+		var fieldType string
+
+		if isLeaf(child) {
+			fieldType = "string"
+		} else {
+			// Use the element's name for its type, as long as nobody else does.
+			fieldType = goName(child.Name)
+			if g.names[fieldType] {
+				fieldType = typeName + fieldType
+			}
+
+			fieldType = uniqueName(fieldType, g.names)
+			g.structType(child, fieldType, false)
+		}
+
+		switch {
+		case child.Max != 1:
+			fieldType = "[]" + fieldType
+		case child.Min == 0 && fieldType != "string":
+			fieldType = "*" + fieldType
+		}
+
+		writeGoDoc(&sb, "\t", fieldName, child.Doc, child.Deprecated)
+		fmt.Fprintf(&sb, "\t%s %s `dyml:\"%s\"`\n", fieldName, fieldType, child.Name)
+	}
+
+	if element.Text != TextForbidden && !root {
+		fmt.Fprintf(&sb, "\t%s string `dyml:\",inner\"`\n", uniqueName("Text", fields))
+	}
+
+	sb.WriteString("}\n")
+
+	g.decls[index] = sb.String()
+}
+
+// isLeaf returns true for elements that only contain text, which become strings.
+func isLeaf(element *ElementSchema) bool {
+	return len(element.Attributes) == 0 && len(element.Children) == 0 && !element.Open
+}
+
+// attributeGoType returns the type of the field for an attribute.
+func attributeGoType(attr *AttributeSchema) string {
+	switch attr.Type {
+	case TypeNumber:
+		return "float64"
+	case TypeBool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// writeGoDoc writes a doc comment for a type or field, if there is something to say.
+func writeGoDoc(sb *strings.Builder, indent, name, doc, deprecated string) {
+	if doc != "" {
+		for i, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+			if i == 0 {
+				line = name + ": " + strings.TrimSpace(line)
+			}
+
+			sb.WriteString(indent + "// " + strings.TrimSpace(line) + "\n")
+		}
+	}
+
+	if deprecated != "" {
+		if doc != "" {
+			sb.WriteString(indent + "//\n")
+		}
+
+		if deprecated == "true" {
+			sb.WriteString(indent + "// Deprecated: do not use.\n")
+		} else {
+			sb.WriteString(indent + "// Deprecated: " + deprecated + "\n")
+		}
+	}
+}
+
+// goName turns an element or attribute name into an exported Go identifier, e.g. "max-size" into "MaxSize".
+func goName(name string) string {
+	var sb strings.Builder
+
+	upper := true
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		sb.WriteRune(r)
+	}
+
+	s := sb.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+
+	return s
+}
+
+// uniqueName returns name, or name with a number appended if it is taken, and marks it as taken.
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+
+	taken[unique] = true
+
+	return unique
+}
+
+// GoPackageName returns the package name of the Go files in dir, or a name derived from the directory.
+func GoPackageName(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	sort.Strings(matches)
+
+	for _, match := range matches {
+		if strings.HasSuffix(match, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), match, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "main"
+	}
+
+	return strings.ToLower(goName(filepath.Base(abs)))
+}
+
+// goTypeName derives a type name from a document's file name, e.g. "Config" from "config.conf.dyml".
+func goTypeName(path string) string {
+	base := filepath.Base(path)
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+
+	return goName(base)
+}
+
+// generatedGoPath is the file the Go code for a document is written to, e.g. "config_dyml.go" for "config.dyml".
+func generatedGoPath(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), ".dyml")
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return '_'
+	}, base)
+
+	return filepath.Join(filepath.Dir(path), base+"_dyml.go")
+}
+
+// GenerateGoFiles generates Go code for a schema, or for a schema inferred from sample documents.
+// The package name defaults to the one in the output's directory and the type name to the name of the first file.
+func GenerateGoFiles(paths []string, output, pkg, typeName string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no files given")
+	}
+
+	var docs []*Document
+
+	for _, path := range paths {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		doc := ParseDocument(File{Uri: pathToURI(path), Content: string(buf)})
+		if doc.Err != nil {
+			return "", fmt.Errorf("%s: %w", path, doc.Err)
+		}
+
+		docs = append(docs, doc)
+	}
+
+	schema, err := schemaOrInferred(docs)
+	if err != nil {
+		return "", err
+	}
+
+	if pkg == "" {
+		dir := filepath.Dir(paths[0])
+		if output != "" {
+			dir = filepath.Dir(output)
+		}
+
+		pkg = GoPackageName(dir)
+	}
+
+	if typeName == "" {
+		typeName = goTypeName(paths[0])
+	}
+
+	return GenerateGo(schema, pkg, typeName)
+}
+
+// schemaOrInferred returns the schema if the only document is one, otherwise a schema is inferred from the documents.
+func schemaOrInferred(docs []*Document) (*Schema, error) {
+	if len(docs) == 1 && IsSchema(docs[0]) {
+		return ParseSchema(docs[0].File)
+	}
+
+	for _, doc := range docs {
+		if IsSchema(doc) {
+			return nil, fmt.Errorf("%s: a schema cannot be combined with other documents", uriToPath(doc.Uri))
+		}
+	}
+
+	return InferSchema(docs), nil
+}
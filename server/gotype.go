@@ -0,0 +1,463 @@
+package dyml
+
+import (
+	"bufio"
+	"dyml-support/protocol"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// goTypeDirective is the comment that associates a document with a Go type, e.g. "#? dyml-go-type ./config.Config".
+// An additional "strict" checks the document as if it was unmarshalled in strict mode.
+const goTypeDirective = "dyml-go-type"
+
+// GoKind is what a GoType is made of, as far as unmarshalling is concerned.
+type GoKind int
+
+const (
+	// GoPrimitive is a string, bool, integer or float.
+	GoPrimitive GoKind = iota
+	GoStruct
+	GoPointer
+	GoSlice
+	GoArray
+	GoMap
+	// GoOpaque types are not checked, because they unmarshal themselves, are parser.TreeNode
+	// or are declared in another package.
+	GoOpaque
+	// GoUnsupported types cannot be unmarshalled, like interfaces, channels or functions.
+	GoUnsupported
+)
+
+// GoType describes a Go type with the details needed to check if a document unmarshals into it.
+type GoType struct {
+	// Name is how the type is written in Go.
+	Name string
+	Kind GoKind
+	// Basic is the predeclared type underlying a primitive, e.g. "int64".
+	Basic string
+	// Key is the key of a map.
+	Key *GoType
+	// Elem is the element type of pointers, slices, arrays and maps.
+	Elem   *GoType
+	Fields []*GoField
+}
+
+// GoField is an exported field of a struct.
+type GoField struct {
+	// Name is the name of the field in Go.
+	Name string
+	// Key is the name of the element or attribute the field is read from.
+	Key string
+	// As is the second part of the dyml tag: "", "attr", "inner" or something invalid.
+	As string
+	// Rename is true if the tag renamed the field, which makes slices collect matching elements.
+	Rename bool
+	Type   *GoType
+}
+
+// Optional returns true for types that have an obvious "not set" value: pointers, slices and maps.
+func (t *GoType) Optional() bool {
+	switch t.Kind {
+	case GoPointer, GoSlice, GoMap:
+		return true
+	default:
+		return false
+	}
+}
+
+// deref returns the type a pointer points to, or the type itself.
+func (t *GoType) deref() *GoType {
+	for t.Kind == GoPointer {
+		t = t.Elem
+	}
+
+	return t
+}
+
+// goPackage is the parsed source of a package, from which we resolve types by name.
+type goPackage struct {
+	dir   string
+	specs map[string]*ast.TypeSpec
+	// custom contains the names of types with an UnmarshalDyml method.
+	custom   map[string]bool
+	resolved map[string]*GoType
+}
+
+// LoadGoType parses the package in dir and resolves the named type.
+// Only the package's own source is read, types from other packages are treated as opaque.
+func LoadGoType(dir, name string) (*GoType, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &goPackage{
+		dir:      dir,
+		specs:    make(map[string]*ast.TypeSpec),
+		custom:   make(map[string]bool),
+		resolved: make(map[string]*GoType),
+	}
+
+	fset := token.NewFileSet()
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		pkg.add(file)
+	}
+
+	if _, ok := pkg.specs[name]; !ok {
+		return nil, fmt.Errorf("type %s not found in %s", name, dir)
+	}
+
+	return pkg.named(name), nil
+}
+
+// add collects the type declarations and UnmarshalDyml methods of a file.
+func (p *goPackage) add(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok {
+					p.specs[spec.Name.Name] = spec
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) == 0 || decl.Name.Name != "UnmarshalDyml" {
+				continue
+			}
+
+			recv := decl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+
+			if ident, ok := recv.(*ast.Ident); ok {
+				p.custom[ident.Name] = true
+			}
+		}
+	}
+}
+
+// named resolves a type declared in the package. Types are cached before their
+// definition is resolved, so that recursive types work.
+func (p *goPackage) named(name string) *GoType {
+	if t, ok := p.resolved[name]; ok {
+		return t
+	}
+
+	t := &GoType{Name: name, Kind: GoOpaque}
+	p.resolved[name] = t
+
+	spec := p.specs[name]
+	if p.custom[name] || spec.TypeParams != nil {
+		return t
+	}
+
+	underlying := p.resolve(spec.Type)
+	if spec.Assign.IsValid() {
+		// An alias is the very same type.
+		*t = *underlying
+
+		return t
+	}
+
+	t.Kind, t.Basic, t.Key, t.Elem, t.Fields = underlying.Kind, underlying.Basic, underlying.Key, underlying.Elem, underlying.Fields
+
+	return t
+}
+
+// resolve turns a type expression into a GoType.
+func (p *goPackage) resolve(expr ast.Expr) *GoType {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if _, ok := p.specs[expr.Name]; ok {
+			return p.named(expr.Name)
+		}
+
+		return predeclared(expr.Name)
+	case *ast.ParenExpr:
+		return p.resolve(expr.X)
+	case *ast.StarExpr:
+		elem := p.resolve(expr.X)
+
+		return &GoType{Name: "*" + elem.Name, Kind: GoPointer, Elem: elem}
+	case *ast.ArrayType:
+		elem := p.resolve(expr.Elt)
+		if expr.Len == nil {
+			return &GoType{Name: "[]" + elem.Name, Kind: GoSlice, Elem: elem}
+		}
+
+		return &GoType{Name: "[...]" + elem.Name, Kind: GoArray, Elem: elem}
+	case *ast.MapType:
+		key, elem := p.resolve(expr.Key), p.resolve(expr.Value)
+
+		return &GoType{Name: "map[" + key.Name + "]" + elem.Name, Kind: GoMap, Key: key, Elem: elem}
+	case *ast.SelectorExpr:
+		// parser.TreeNode is the most common one here. We do not load other packages.
+		if pkg, ok := expr.X.(*ast.Ident); ok {
+			return &GoType{Name: pkg.Name + "." + expr.Sel.Name, Kind: GoOpaque}
+		}
+
+		return &GoType{Name: expr.Sel.Name, Kind: GoOpaque}
+	case *ast.StructType:
+		return &GoType{Name: "struct", Kind: GoStruct, Fields: p.fields(expr)}
+	case *ast.InterfaceType:
+		return &GoType{Name: "interface", Kind: GoUnsupported}
+	case *ast.ChanType:
+		return &GoType{Name: "chan", Kind: GoUnsupported}
+	case *ast.FuncType:
+		return &GoType{Name: "func", Kind: GoUnsupported}
+	default:
+		// Instantiated generics and anything newer than this code.
+		return &GoType{Name: "?", Kind: GoOpaque}
+	}
+}
+
+// fields returns the exported fields of a struct, named and tagged like the unmarshaller sees them.
+func (p *goPackage) fields(st *ast.StructType) []*GoField {
+	var fields []*GoField
+
+	for _, field := range st.Fields.List {
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+
+		if len(names) == 0 {
+			// Embedded fields are named after their type.
+			names = append(names, embeddedName(field.Type))
+		}
+
+		var tag reflect.StructTag
+
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted)
+			}
+		}
+
+		typ := p.resolve(field.Type)
+
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+
+			f := &GoField{Name: name, Key: name, Type: typ}
+
+			if value, ok := tag.Lookup("dyml"); ok {
+				parts := strings.Split(value, ",")
+				if parts[0] != "" {
+					f.Key, f.Rename = parts[0], true
+				}
+
+				if len(parts) > 1 {
+					f.As = parts[1]
+				}
+			}
+
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// embeddedName returns the name of an embedded field.
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	case *ast.IndexExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexListExpr:
+		return embeddedName(expr.X)
+	default:
+		return ""
+	}
+}
+
+// predeclared returns the GoType for one of Go's predeclared types.
+func predeclared(name string) *GoType {
+	switch name {
+	case "string", "bool",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64":
+		return &GoType{Name: name, Kind: GoPrimitive, Basic: name}
+	case "byte":
+		return &GoType{Name: name, Kind: GoPrimitive, Basic: "uint8"}
+	case "rune":
+		return &GoType{Name: name, Kind: GoPrimitive, Basic: "int32"}
+	default:
+		// any, uintptr, complex numbers and error.
+		return &GoType{Name: name, Kind: GoUnsupported}
+	}
+}
+
+// CheckValue returns a problem description if the text cannot be unmarshalled into the primitive type.
+func (t *GoType) CheckValue(text string) string {
+	var err error
+
+	trimmed := strings.TrimSpace(text)
+
+	switch {
+	case t.Basic == "string":
+		return ""
+	case t.Basic == "bool":
+		_, err = strconv.ParseBool(trimmed)
+	case strings.HasPrefix(t.Basic, "int"):
+		_, err = strconv.ParseInt(trimmed, 10, bitSize(t.Basic, "int"))
+	case strings.HasPrefix(t.Basic, "uint"):
+		_, err = strconv.ParseUint(trimmed, 10, bitSize(t.Basic, "uint"))
+	case strings.HasPrefix(t.Basic, "float"):
+		_, err = strconv.ParseFloat(trimmed, bitSize(t.Basic, "float"))
+	}
+
+	if err == nil {
+		return ""
+	}
+
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return fmt.Sprintf("'%s' is out of range for %s", text, t.Name)
+	}
+
+	return fmt.Sprintf("'%s' is not a valid %s", text, t.Name)
+}
+
+// bitSize returns the size of a sized integer or float, e.g. 16 for "int16".
+// Unsized types have 0, which strconv treats as the size of int.
+func bitSize(basic, prefix string) int {
+	size, err := strconv.Atoi(strings.TrimPrefix(basic, prefix))
+	if err != nil {
+		if prefix == "float" {
+			return 64
+		}
+
+		return 0
+	}
+
+	return size
+}
+
+// splitGoType splits a type reference like "./config.Config" into the package and the type name.
+// A name without a package refers to the package in the base directory.
+func splitGoType(ref string) (string, string) {
+	slash := strings.LastIndex(ref, "/")
+
+	dot := strings.LastIndex(ref[slash+1:], ".")
+	if dot < 0 {
+		return ".", ref
+	}
+
+	return ref[:slash+1+dot], ref[slash+1+dot+1:]
+}
+
+// goPackageDir finds the directory of a package. Paths starting with '.' or '/' are directories
+// relative to baseDir, everything else is an import path in the module that contains baseDir.
+func goPackageDir(baseDir, pkg string) (string, error) {
+	if filepath.IsAbs(pkg) {
+		return pkg, nil
+	}
+
+	if pkg == "." || pkg == ".." || strings.HasPrefix(pkg, "./") || strings.HasPrefix(pkg, "../") {
+		return filepath.Join(baseDir, filepath.FromSlash(pkg)), nil
+	}
+
+	modDir, modPath, err := findModule(baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	if pkg == modPath {
+		return modDir, nil
+	}
+
+	if !strings.HasPrefix(pkg, modPath+"/") {
+		return "", fmt.Errorf("package %s is not part of module %s", pkg, modPath)
+	}
+
+	return filepath.Join(modDir, filepath.FromSlash(strings.TrimPrefix(pkg, modPath+"/"))), nil
+}
+
+// findModule searches dir and its parents for a go.mod and returns its directory and module path.
+func findModule(dir string) (string, string, error) {
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) >= 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), nil
+				}
+			}
+
+			return "", "", fmt.Errorf("no module path in %s", f.Name())
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("no go.mod found")
+		}
+
+		dir = parent
+	}
+}
+
+// goTypeRef is a reference to the Go type a document is unmarshalled into.
+type goTypeRef struct {
+	// dir is the directory of the package that declares the type.
+	dir  string
+	name string
+	// strict is true if the document is unmarshalled in strict mode.
+	strict bool
+	// where is the range of the directive, or the start of the document if the type was configured.
+	where protocol.Range
+}
+
+// goTypeFor finds the Go type a document is unmarshalled into. A directive in the document takes
+// precedence over the globs in the configuration.
+func (s *Server) goTypeFor(doc *Document) (goTypeRef, bool, error) {
+	if args, where, ok := findDirective(doc, goTypeDirective); ok && len(args) > 0 {
+		ref := goTypeRef{where: where, strict: len(args) > 1 && args[1] == "strict"}
+		pkg, name := splitGoType(args[0])
+		dir, err := goPackageDir(filepath.Dir(uriToPath(doc.Uri)), pkg)
+		ref.dir, ref.name = dir, name
+
+		return ref, true, err
+	}
+
+	typeName, folderPath, ok := s.configured(doc, s.config.GoTypes)
+	if !ok {
+		return goTypeRef{}, false, nil
+	}
+
+	pkg, name := splitGoType(typeName)
+	dir, err := goPackageDir(folderPath, pkg)
+
+	return goTypeRef{dir: dir, name: name, strict: s.config.GoStrict}, true, err
+}
@@ -0,0 +1,37 @@
+package dyml
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGoTypeFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		dir     string
+		want    string
+		strict  bool
+	}{
+		{"directive", "#? dyml-go-type ./config.Config\n#a {x}\n", "/dir/config", "Config", false},
+		{"strict", "#? dyml-go-type ./config.Config strict\n#a {x}\n", "/dir/config", "Config", true},
+		{"followed by text", "#? dyml-go-type ./config.Config\nstrict text\n#a {x}\n", "/dir/config", "Config", false},
+		{"type in the same package", "#? dyml-go-type Config\nother.Type\n#a {x}\n", "/dir", "Config", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			doc := ParseDocument(File{Uri: "file:///dir/doc.dyml", Content: tt.content})
+
+			ref, ok, err := s.goTypeFor(doc)
+			if !ok || err != nil {
+				t.Fatalf("got %v, %v", ok, err)
+			}
+
+			if ref.dir != filepath.FromSlash(tt.dir) || ref.name != tt.want || ref.strict != tt.strict {
+				t.Errorf("got %s %s strict %v, want %s %s strict %v", ref.dir, ref.name, ref.strict, tt.dir, tt.want, tt.strict)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// defaultInclude is the include construct, unless others are configured: #include @src{other.dyml}.
+const defaultInclude = "include@src"
+
+// includeSpec is an include construct, written as "element@attribute" for elements that are replaced by
+// the included document, or "@attribute" for an attribute of any element, which gets the included
+// document as additional children.
+type includeSpec struct {
+	element   string
+	attribute string
+}
+
+// parseIncludeSpec reads an include construct from the settings.
+func parseIncludeSpec(spec string) (includeSpec, bool) {
+	i := strings.Index(spec, "@")
+	if i < 0 || i == len(spec)-1 {
+		return includeSpec{}, false
+	}
+
+	return includeSpec{element: spec[:i], attribute: spec[i+1:]}, true
+}
+
+// includeSpecs returns the configured include constructs.
+func (s *Server) includeSpecs() []includeSpec {
+	specs := s.config.Includes
+	if specs == nil {
+		specs = []string{defaultInclude}
+	}
+
+	var parsed []includeSpec
+
+	for _, spec := range specs {
+		if p, ok := parseIncludeSpec(spec); ok {
+			parsed = append(parsed, p)
+		}
+	}
+
+	return parsed
+}
+
+// include is an element that includes another document.
+type include struct {
+	node *Node
+	attr *Attribute
+	uri  protocol.DocumentURI
+	// replace is true if the element is replaced by the included document, instead of containing it.
+	replace bool
+}
+
+// includeOf returns the include of an element, if it has one.
+func includeOf(doc *Document, node *Node, specs []includeSpec) (include, bool) {
+	if !node.IsElement() || node.IsRoot() {
+		return include{}, false
+	}
+
+	for _, spec := range specs {
+		if spec.element != "" && spec.element != node.Name {
+			continue
+		}
+
+		attr := node.Attribute(spec.attribute)
+		if attr == nil || strings.TrimSpace(attr.Value) == "" {
+			continue
+		}
+
+		return include{
+			node:    node,
+			attr:    attr,
+			uri:     resolveURI(doc.Uri, strings.TrimSpace(attr.Value)),
+			replace: spec.element != "",
+		}, true
+	}
+
+	return include{}, false
+}
+
+// includesOf returns all includes of a document.
+func (s *Server) includesOf(doc *Document) []include {
+	var includes []include
+
+	specs := s.includeSpecs()
+
+	doc.Root.Walk(func(node *Node) bool {
+		if inc, ok := includeOf(doc, node, specs); ok {
+			includes = append(includes, inc)
+		}
+
+		return true
+	})
+
+	return includes
+}
+
+// includeAt returns the include whose path contains the position.
+func (s *Server) includeAt(doc *Document, pos protocol.Position) (include, bool) {
+	for _, inc := range s.includesOf(doc) {
+		if rangeContains(valueRange(inc.attr), pos) {
+			return inc, true
+		}
+	}
+
+	return include{}, false
+}
+
+// includeCycle returns the chain of documents, through which the document at from includes the document at to.
+// It returns nil if it does not include it.
+func (s *Server) includeCycle(from, to protocol.DocumentURI, visited map[string]bool) []protocol.DocumentURI {
+	if uriToPath(from) == uriToPath(to) {
+		return []protocol.DocumentURI{from}
+	}
+
+	if visited[uriToPath(from)] {
+		return nil
+	}
+
+	visited[uriToPath(from)] = true
+
+	content, err := s.readFile(from)
+	if err != nil {
+		return nil
+	}
+
+	for _, inc := range s.includesOf(ParseDocument(File{Uri: from, Content: content})) {
+		if chain := s.includeCycle(inc.uri, to, visited); chain != nil {
+			return append([]protocol.DocumentURI{from}, chain...)
+		}
+	}
+
+	return nil
+}
+
+// includeProblems reports includes of files that do not exist, and includes that include the document again.
+func (s *Server) includeProblems(doc *Document) []Problem {
+	var problems []Problem
+
+	for _, inc := range s.includesOf(doc) {
+		where := valueRange(inc.attr)
+
+		if _, err := s.readFile(inc.uri); err != nil {
+			problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+				Range:           where,
+				Severity:        protocol.SeverityError,
+				Code:            "include-not-found",
+				CodeDescription: codeDescription("include-not-found"),
+				Message:         fmt.Sprintf("included file %s does not exist", inc.attr.Value),
+			}})
+
+			continue
+		}
+
+		if chain := s.includeCycle(inc.uri, doc.Uri, make(map[string]bool)); chain != nil {
+			names := []string{filepath.Base(uriToPath(doc.Uri))}
+			for _, uri := range chain {
+				names = append(names, filepath.Base(uriToPath(uri)))
+			}
+
+			problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+				Range:           where,
+				Severity:        protocol.SeverityError,
+				Code:            "include-cycle",
+				CodeDescription: codeDescription("include-cycle"),
+				Message:         fmt.Sprintf("include cycle: %s", strings.Join(names, " -> ")),
+			}})
+		}
+	}
+
+	return problems
+}
+
+// includeLinks links the paths of all includes to the included documents.
+func (s *Server) includeLinks(doc *Document) []protocol.DocumentLink {
+	var links []protocol.DocumentLink
+
+	for _, inc := range s.includesOf(doc) {
+		links = append(links, protocol.DocumentLink{
+			Range:   valueRange(inc.attr),
+			Target:  string(inc.uri),
+			Tooltip: "Open included document",
+		})
+	}
+
+	return links
+}
+
+// resolveInclude returns the include of an element and the included document, for xmlWriter.
+func (s *Server) resolveInclude(doc *Document, node *Node) (include, *Document, bool) {
+	inc, ok := includeOf(doc, node, s.includeSpecs())
+	if !ok {
+		return include{}, nil, false
+	}
+
+	content, err := s.readFile(inc.uri)
+	if err != nil {
+		return include{}, nil, false
+	}
+
+	return inc, ParseDocument(File{Uri: inc.uri, Content: content}), true
+}
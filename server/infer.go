@@ -0,0 +1,254 @@
+package dyml
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxEnumValues is the maximum number of distinct values an attribute may have to be inferred as an enum.
+const maxEnumValues = 8
+
+// elementStats collects what we saw of all elements at one path while inferring a schema.
+type elementStats struct {
+	name string
+	// count is the number of times this element occurred.
+	count int
+	// parentsWith is the number of parent elements this element occurred in.
+	parentsWith int
+	// maxPerParent is how often this element occurred in a single parent at most.
+	maxPerParent int
+	withText     int
+	attributes   map[string]*attributeStats
+	// attributeOrder and childOrder keep the order in which things were first seen.
+	attributeOrder []string
+	children       map[string]*elementStats
+	childOrder     []string
+}
+
+// attributeStats collects the values of an attribute.
+type attributeStats struct {
+	count  int
+	values map[string]int
+}
+
+func newElementStats(name string) *elementStats {
+	return &elementStats{
+		name:       name,
+		attributes: make(map[string]*attributeStats),
+		children:   make(map[string]*elementStats),
+	}
+}
+
+// add records an occurrence of node, which must have the name of these stats.
+func (e *elementStats) add(node *Node) {
+	e.count++
+
+	for _, attr := range node.Attributes {
+		stats, ok := e.attributes[attr.Key]
+		if !ok {
+			stats = &attributeStats{values: make(map[string]int)}
+			e.attributes[attr.Key] = stats
+			e.attributeOrder = append(e.attributeOrder, attr.Key)
+		}
+
+		stats.count++
+		stats.values[attr.Value]++
+	}
+
+	hasText := false
+	perName := make(map[string]int)
+
+	for _, child := range node.Children {
+		switch {
+		case child.Text != nil:
+			if strings.TrimSpace(*child.Text) != "" && !hasText {
+				hasText = true
+				e.withText++
+			}
+		case child.IsElement():
+			stats, ok := e.children[child.Name]
+			if !ok {
+				stats = newElementStats(child.Name)
+				e.children[child.Name] = stats
+				e.childOrder = append(e.childOrder, child.Name)
+			}
+
+			perName[child.Name]++
+			stats.add(child)
+		}
+	}
+
+	for name, stats := range e.children {
+		if n := perName[name]; n > 0 {
+			stats.parentsWith++
+			if n > stats.maxPerParent {
+				stats.maxPerParent = n
+			}
+		}
+	}
+}
+
+// schema turns the collected stats into a schema for the element.
+func (e *elementStats) schema() *ElementSchema {
+	element := &ElementSchema{
+		Name: e.name,
+		Max:  -1,
+		Text: TextAllowed,
+	}
+
+	if e.maxPerParent == 1 {
+		element.Max = 1
+	}
+
+	switch e.withText {
+	case 0:
+		element.Text = TextForbidden
+	case e.count:
+		element.Text = TextRequired
+	}
+
+	for _, key := range e.attributeOrder {
+		element.Attributes = append(element.Attributes, e.attributes[key].schema(key, e.count))
+	}
+
+	for _, name := range e.childOrder {
+		child := e.children[name]
+		childSchema := child.schema()
+
+		// A child that occurred in every parent is required.
+		if child.parentsWith == e.count {
+			childSchema.Min = 1
+		}
+
+		element.Children = append(element.Children, childSchema)
+	}
+
+	return element
+}
+
+// schema infers the type of the attribute. elementCount is the number of elements that could have had it.
+func (a *attributeStats) schema(name string, elementCount int) *AttributeSchema {
+	attr := &AttributeSchema{
+		Name:     name,
+		Type:     TypeString,
+		Required: a.count == elementCount,
+	}
+
+	values := make([]string, 0, len(a.values))
+	for value := range a.values {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	isNumber, isBool := true, true
+
+	for _, value := range values {
+		if !finiteNumber(value) {
+			isNumber = false
+		}
+
+		if value != "true" && value != "false" {
+			isBool = false
+		}
+	}
+
+	switch {
+	case isBool:
+		attr.Type = TypeBool
+	case isNumber:
+		attr.Type = TypeNumber
+	case len(values) <= maxEnumValues && a.count > len(values) && !strings.ContainsAny(strings.Join(values, ""), ",\n"):
+		// Only values that repeat are likely to be from a fixed set.
+		attr.Type = TypeEnum
+		attr.Enum = values
+	}
+
+	return attr
+}
+
+// finiteNumber returns true if a value is a number. ParseFloat also reads words like "NaN" and "Inf",
+// which are more likely text than numbers.
+func finiteNumber(value string) bool {
+	number, err := strconv.ParseFloat(value, 64)
+
+	return err == nil && !math.IsNaN(number) && !math.IsInf(number, 0)
+}
+
+// InferSchema creates a schema that all documents are valid against.
+// Element nesting, attribute types, optionality and cardinality are derived from what occurs in the documents.
+func InferSchema(docs []*Document) *Schema {
+	root := newElementStats("root")
+
+	for _, doc := range docs {
+		root.add(doc.Root)
+	}
+
+	schema := &Schema{Root: root.schema()}
+	schema.Root.Text = TextAllowed
+
+	return schema
+}
+
+// InferSchemaDir parses all DYML documents in a directory and its subdirectories and infers a schema.
+// Documents that cannot be parsed are skipped and returned as errors.
+// Schemas in the directory are skipped, so that a previously inferred schema does not influence the result.
+func InferSchemaDir(dir string) (*Schema, []error) {
+	var (
+		docs []*Document
+		errs []error
+	)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+
+			return nil
+		}
+
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".dyml" {
+			return nil
+		}
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+
+			return nil
+		}
+
+		doc := ParseDocument(File{Uri: pathToURI(path), Content: string(buf)})
+		if doc.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, doc.Err))
+
+			return nil
+		}
+
+		if IsSchema(doc) {
+			return nil
+		}
+
+		docs = append(docs, doc)
+
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return InferSchema(docs), errs
+}
@@ -0,0 +1,40 @@
+package dyml
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestInferSchemaEscapes infers a schema from values with characters that must be escaped,
+// which must parse to the same schema again.
+func TestInferSchemaEscapes(t *testing.T) {
+	content := `#item @path{C:\\dir} @note{a \} b} {x}
+#item @path{C:\\dir} {y}
+#item @path{D:\\} {z}
+#item @path{D:\\} {z}
+`
+
+	schema := InferSchema([]*Document{ParseDocument(File{Uri: "file:///test.dyml", Content: content})})
+	item := schema.Root.Child("item")
+	item.Doc = `Paths like C:\dir {or} D:\`
+
+	path := item.Attribute("path")
+	if path == nil || path.Type != TypeEnum {
+		t.Fatalf("got %+v, want an enum", path)
+	}
+
+	parsed, err := ParseSchema(File{Uri: "file:///test.schema.dyml", Content: schema.Format()})
+	if err != nil {
+		t.Fatalf("parsing\n%s\n%v", schema.Format(), err)
+	}
+
+	if parsed.Root.Child("item").Doc != item.Doc {
+		t.Errorf("got doc %q, want %q", parsed.Root.Child("item").Doc, item.Doc)
+	}
+
+	for _, attr := range item.Attributes {
+		if got := parsed.Root.Child("item").Attribute(attr.Name); !reflect.DeepEqual(got, attr) {
+			t.Errorf("got %+v, want %+v", got, attr)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package dyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// jsonValue is a value of a document converted for JSON and YAML: a string, a *jsonObject or a list of them.
+type jsonValue interface{}
+
+// jsonObject is an object whose keys keep the order of the document.
+type jsonObject struct {
+	keys   []string
+	values map[string]jsonValue
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]jsonValue{}}
+}
+
+// add sets a key of the object. Keys that are added more than once get a list of all values.
+func (o *jsonObject) add(key string, value jsonValue) {
+	old, ok := o.values[key]
+	list, isList := old.([]jsonValue)
+
+	switch {
+	case !ok:
+		o.keys = append(o.keys, key)
+		o.values[key] = value
+	case isList:
+		o.values[key] = append(list, value)
+	default:
+		o.values[key] = []jsonValue{old, value}
+	}
+}
+
+// MarshalJSON writes the object with its keys in order.
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := marshalJSON(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal without escaping '<', '>' and '&', which are common in text.
+func marshalJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// jsonBuilder converts the tree of a document to objects, for JSON and YAML:
+//
+//   - elements are objects, which have their name as key in the object of their parent
+//   - attributes are strings with the key "@" and their name
+//   - text is a string with the key "#text"
+//   - an element that only contains text is the text itself
+//   - elements, attributes and text that appear more than once in an element are lists
+//
+// Comments are left out and so is the order of elements with different names.
+type jsonBuilder struct {
+	expander
+}
+
+// object returns the object for nodes of a document.
+func (b *jsonBuilder) object(nodes []*Node) *jsonObject {
+	obj := newJSONObject()
+
+	for _, node := range nodes {
+		b.add(obj, node)
+	}
+
+	return obj
+}
+
+// add adds an element or text to the object of its parent.
+func (b *jsonBuilder) add(parent *jsonObject, node *Node) {
+	switch {
+	case node.Comment != nil:
+	case node.Text != nil:
+		if text := strings.TrimSpace(*node.Text); text != "" {
+			parent.add("#text", text)
+		}
+	default:
+		b.element(parent, node)
+	}
+}
+
+// element adds an element with its attributes and children to the object of its parent.
+func (b *jsonBuilder) element(parent *jsonObject, node *Node) {
+	inc, included, expand := b.included(node)
+
+	// Like in XML, include elements are replaced by the included document,
+	// other elements get its content as additional children.
+	if expand && inc.replace {
+		b.content(parent, included)
+
+		return
+	}
+
+	if isNamedReturn(b.current(), node) {
+		b.element(parent, node.Children[0])
+
+		return
+	}
+
+	obj := newJSONObject()
+
+	for _, attr := range node.Attributes {
+		if expand && attr == inc.attr {
+			continue
+		}
+
+		obj.add("@"+attr.Key, attr.Value)
+	}
+
+	for _, child := range node.Children {
+		b.add(obj, child)
+	}
+
+	if expand {
+		b.content(obj, included)
+	}
+
+	if text, ok := obj.values["#text"].(string); ok && len(obj.keys) == 1 {
+		parent.add(node.Name, text)
+	} else {
+		parent.add(node.Name, obj)
+	}
+}
+
+// content adds the top level of an included document to an object.
+func (b *jsonBuilder) content(obj *jsonObject, doc *Document) {
+	b.within(doc, func() {
+		for _, child := range doc.Root.Children {
+			b.add(obj, child)
+		}
+	})
+}
+
+// buildJSON returns the object for nodes of a document. Includes are resolved with the given function.
+func buildJSON(doc *Document, nodes []*Node, options XMLOptions, resolve func(doc *Document, node *Node) (include, *Document, bool)) *jsonObject {
+	b := &jsonBuilder{expander{expand: options.ExpandIncludes, resolve: resolve, docs: []*Document{doc}}}
+
+	return b.object(nodes)
+}
+
+// writeJSON returns the JSON for an object, indented like the options say.
+func writeJSON(obj *jsonObject, options XMLOptions) string {
+	var sb strings.Builder
+
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+
+	if !options.Minify {
+		indent := options.Indent
+		if indent == "" {
+			indent = "    "
+		}
+
+		enc.SetIndent("", indent)
+	}
+
+	// Objects only contain strings, which can always be encoded.
+	_ = enc.Encode(obj)
+
+	return sb.String()
+}
@@ -0,0 +1,174 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/golangee/dyml/token"
+)
+
+// linkWord matches words in attribute values and text, that might be links.
+var linkWord = regexp.MustCompile(`[^\s"'<>(){}\[\],;]+`)
+
+// pathWord matches relative and absolute file paths, like "docs/readme.md" or "../config".
+var pathWord = regexp.MustCompile(`^[A-Za-z0-9_.~\-/]*/[A-Za-z0-9_.~\-/]*$`)
+
+// fileExtension matches the extension of a file name, it has to start with a letter to not be confused with numbers.
+var fileExtension = regexp.MustCompile(`\.[A-Za-z][A-Za-z0-9]*$`)
+
+// textLink is a URL or file path found in an attribute value or text.
+type textLink struct {
+	where  protocol.Range
+	target protocol.DocumentURI
+	// isFile is true for file paths and file URIs, which should point to existing files.
+	isFile bool
+}
+
+// linkTarget returns where a word links to, if it looks like a http(s) URL, a file URI or a path.
+// Paths must contain a '/' and end with a file extension or start with "./" or "../",
+// so that text like "and/or" is not mistaken for a path.
+func linkTarget(base protocol.DocumentURI, word string) (protocol.DocumentURI, bool, bool) {
+	switch {
+	case strings.HasPrefix(word, "http://"), strings.HasPrefix(word, "https://"):
+		return protocol.DocumentURI(word), false, true
+	case strings.HasPrefix(word, "file://"):
+		return protocol.DocumentURI(word), true, true
+	case !pathWord.MatchString(word):
+		return "", false, false
+	}
+
+	name := word[strings.LastIndex(word, "/")+1:]
+	if !fileExtension.MatchString(name) && !strings.HasPrefix(word, "./") && !strings.HasPrefix(word, "../") {
+		return "", false, false
+	}
+
+	return resolveURI(base, word), true, true
+}
+
+// textLinks finds links in the CharData tokens of attribute values and text. Comments are left out.
+func textLinks(doc *Document) []textLink {
+	var links []textLink
+
+	for i, tok := range doc.Tokens {
+		charData, ok := tok.(*token.CharData)
+		if !ok {
+			continue
+		}
+
+		if i > 0 && (doc.Tokens[i-1].Type() == token.TokenG1Comment || doc.Tokens[i-1].Type() == token.TokenG2Comment) {
+			continue
+		}
+
+		// The source is searched instead of the value, so that positions are right even with escaped characters.
+		start := toRange(charData.Position).Start
+		source := doc.textIn(toRange(charData.Position))
+
+		for _, match := range linkWord.FindAllStringIndex(source, -1) {
+			word := strings.TrimRight(source[match[0]:match[1]], ".:!?")
+
+			target, isFile, ok := linkTarget(doc.Uri, word)
+			if !ok {
+				continue
+			}
+
+			links = append(links, textLink{
+				where: protocol.Range{
+					Start: advance(start, source[:match[0]]),
+					End:   advance(start, source[:match[0]+len(word)]),
+				},
+				target: target,
+				isFile: isFile,
+			})
+		}
+	}
+
+	return links
+}
+
+// advance returns the position after the text, if it starts at the given position.
+func advance(pos protocol.Position, text string) protocol.Position {
+	if i := strings.LastIndex(text, "\n"); i >= 0 {
+		pos.Line += uint32(strings.Count(text, "\n"))
+		pos.Character = 0
+		text = text[i+1:]
+	}
+
+	pos.Character += uint32(len([]rune(text)))
+
+	return pos
+}
+
+// fileLinks returns the links in a document, that are not the paths of includes, which are handled on their own.
+func (s *Server) fileLinks(doc *Document) []textLink {
+	var links []textLink
+
+	includes := s.includesOf(doc)
+
+outer:
+	for _, link := range textLinks(doc) {
+		for _, inc := range includes {
+			if rangeContains(valueRange(inc.attr), link.where.Start) {
+				continue outer
+			}
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// fileExists returns true if the file is open or exists in the file system.
+func (s *Server) fileExists(uri protocol.DocumentURI) bool {
+	if s.isOpen(uriToPath(uri)) {
+		return true
+	}
+
+	_, err := os.Stat(uriToPath(uri))
+
+	return err == nil
+}
+
+// linkProblems warns about links to files that do not exist.
+func (s *Server) linkProblems(doc *Document) []Problem {
+	var problems []Problem
+
+	for _, link := range s.fileLinks(doc) {
+		if !link.isFile || s.fileExists(link.target) {
+			continue
+		}
+
+		problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+			Range:           link.where,
+			Severity:        protocol.SeverityWarning,
+			Code:            "link-not-found",
+			CodeDescription: codeDescription("link-not-found"),
+			Message:         fmt.Sprintf("file %s does not exist", doc.textIn(link.where)),
+		}})
+	}
+
+	return problems
+}
+
+// DocumentLink links the paths of includes to the included documents, and URLs and file paths
+// in attribute values and text to their targets.
+func (s *Server) DocumentLink(params *protocol.DocumentLinkParams) []protocol.DocumentLink {
+	links := []protocol.DocumentLink{}
+
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return links
+	}
+
+	doc := ParseDocument(file)
+	links = append(links, s.includeLinks(doc)...)
+
+	for _, link := range s.fileLinks(doc) {
+		links = append(links, protocol.DocumentLink{Range: link.where, Target: string(link.target)})
+	}
+
+	return links
+}
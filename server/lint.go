@@ -0,0 +1,274 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"strings"
+)
+
+// LintRule checks documents for likely mistakes or bad style that are not syntax errors.
+type LintRule struct {
+	// ID identifies the rule in the configuration and diagnostics.
+	ID          string
+	Description string
+	// Severity is used, unless the configuration overrides it.
+	Severity protocol.DiagnosticSeverity
+	// Tags are added to all diagnostics of the rule, e.g. to fade out what can be removed.
+	Tags  []protocol.DiagnosticTag
+	check func(l *linter, doc *Document)
+}
+
+// LintRules are all rules we check documents with.
+var LintRules = []*LintRule{
+	{
+		ID:          "duplicate-attribute",
+		Description: "An attribute is defined multiple times on an element, or only differs in case from another one.",
+		Severity:    protocol.SeverityWarning,
+		check:       lintDuplicateAttributes,
+	},
+	{
+		ID:          "empty-block",
+		Description: "An element has brackets without anything inside.",
+		Severity:    protocol.SeverityInformation,
+		Tags:        []protocol.DiagnosticTag{protocol.Unnecessary},
+		check:       lintEmptyBlocks,
+	},
+	{
+		ID:          "mixed-grammar",
+		Description: "A document mixes elements in G1 and G2 syntax.",
+		Severity:    protocol.SeverityInformation,
+		check:       lintMixedGrammar,
+	},
+	{
+		ID:          "forward-outside-block",
+		Description: "A forwarded attribute or element with '@@' or '##' has no receiver in its own block and ends up in an element after the block.",
+		Severity:    protocol.SeverityWarning,
+		check:       lintForwardsOutsideBlock,
+	},
+	{
+		ID:          "trailing-whitespace",
+		Description: "A line ends with spaces or tabs.",
+		Severity:    protocol.SeverityInformation,
+		Tags:        []protocol.DiagnosticTag{protocol.Unnecessary},
+		check:       lintTrailingWhitespace,
+	},
+	{
+		ID:          "deep-nesting",
+		Description: "Elements are nested deeper than the configured maximum depth.",
+		Severity:    protocol.SeverityWarning,
+		check:       lintDeepNesting,
+	},
+}
+
+// defaultMaxDepth is how deep elements may be nested, if the configuration does not say otherwise.
+const defaultMaxDepth = 8
+
+// LintConfig holds the settings for linting.
+type LintConfig struct {
+	// Rules overrides the severity of rules by their ID: "error", "warning", "information", "hint" or "off".
+	Rules map[string]string `json:"rules"`
+	// MaxDepth is the depth of nesting at which "deep-nesting" reports elements.
+	MaxDepth int `json:"maxDepth"`
+}
+
+// severity returns the configured severity of a rule and false if the rule is turned off.
+func (c LintConfig) severity(rule *LintRule) (protocol.DiagnosticSeverity, bool) {
+	switch strings.ToLower(c.Rules[rule.ID]) {
+	case "off", "none", "false":
+		return 0, false
+	case "error":
+		return protocol.SeverityError, true
+	case "warning":
+		return protocol.SeverityWarning, true
+	case "information", "info":
+		return protocol.SeverityInformation, true
+	case "hint":
+		return protocol.SeverityHint, true
+	default:
+		return rule.Severity, true
+	}
+}
+
+// linter collects the problems a rule finds.
+type linter struct {
+	validator
+	rule     *LintRule
+	severity protocol.DiagnosticSeverity
+	config   LintConfig
+}
+
+// problem reports a finding of the current rule.
+func (l *linter) problem(where protocol.Range, fixes []Fix, format string, args ...interface{}) *protocol.Diagnostic {
+	d := l.report(l.rule.ID, l.severity, where, fixes, format, args...)
+	d.Tags = l.rule.Tags
+
+	return d
+}
+
+// Lint checks a document with all rules that are not turned off.
+func Lint(doc *Document, config LintConfig) []Problem {
+	l := linter{config: config}
+
+	for _, rule := range LintRules {
+		severity, ok := config.severity(rule)
+		if !ok {
+			continue
+		}
+
+		l.rule, l.severity = rule, severity
+		rule.check(&l, doc)
+	}
+
+	return l.problems
+}
+
+func lintDuplicateAttributes(l *linter, doc *Document) {
+	doc.Root.Walk(func(node *Node) bool {
+		seen := make(map[string]*Attribute)
+
+		for _, attr := range node.Attributes {
+			first, ok := seen[strings.ToLower(attr.Key)]
+			if !ok {
+				seen[strings.ToLower(attr.Key)] = attr
+
+				continue
+			}
+
+			fix := removeFix(fmt.Sprintf("Remove duplicate attribute '%s'", attr.Key), attr.Range)
+			if first.Key == attr.Key {
+				l.problem(attr.KeyRange, []Fix{fix}, "attribute '%s' is defined multiple times on %s", attr.Key, describe(node))
+			} else {
+				l.problem(attr.KeyRange, []Fix{fix}, "attribute '%s' only differs in case from '%s' on %s", attr.Key, first.Key, describe(node))
+			}
+		}
+
+		return true
+	})
+}
+
+func lintEmptyBlocks(l *linter, doc *Document) {
+	doc.Root.Walk(func(node *Node) bool {
+		if node.IsRoot() || node.BlockRange == nil || len(node.Children) > 0 {
+			return true
+		}
+
+		l.problem(*node.BlockRange, []Fix{removeFix("Remove empty brackets", *node.BlockRange)}, "'%s' has an empty block", node.Name)
+
+		return true
+	})
+}
+
+func lintMixedGrammar(l *linter, doc *Document) {
+	elements := doc.Root.Elements()
+	if len(elements) == 0 {
+		return
+	}
+
+	// The grammar of the first element is what the document is written in.
+	g2 := elements[0].G2
+
+	doc.Root.Walk(func(node *Node) bool {
+		if !node.IsElement() || node.IsRoot() {
+			return true
+		}
+
+		expected := g2
+		if !node.Parent.IsRoot() {
+			expected = node.Parent.G2
+		}
+
+		if node.G2 == expected {
+			return true
+		}
+
+		if node.G2 {
+			l.problem(node.NameRange, nil, "'%s' is written in G2, while the document uses G1", node.Name)
+		} else {
+			l.problem(node.NameRange, nil, "'%s' is written in G1, while the document uses G2", node.Name)
+		}
+
+		// Report only where the grammar changes, not for everything inside.
+		return false
+	})
+}
+
+func lintForwardsOutsideBlock(l *linter, doc *Document) {
+	doc.Root.Walk(func(node *Node) bool {
+		if node.IsRoot() {
+			return true
+		}
+
+		for _, attr := range node.Attributes {
+			if attr.Forwarded && doc.ContainerAt(attr.Range.Start) != blockOf(node.Parent) {
+				l.problem(attr.KeyRange, nil, "forwarded attribute '%s' has no element to go to in its block and ends up in '%s'", attr.Key, node.Name)
+			}
+		}
+
+		if node.Forwarded && !node.Parent.IsRoot() && doc.ContainerAt(node.Range.Start) != blockOf(node.Parent.Parent) {
+			l.problem(nameRange(node), nil, "forwarded %s has no element to go to in its block and ends up in '%s'", forwardedWhat(node), node.Parent.Name)
+		}
+
+		return true
+	})
+}
+
+// blockOf returns the closest element with brackets around its children, which is where
+// the children were written. G2 elements can contain others without brackets.
+func blockOf(node *Node) *Node {
+	for node.BlockRange == nil && !node.IsRoot() {
+		node = node.Parent
+	}
+
+	return node
+}
+
+// forwardedWhat describes a forwarded node in a message.
+func forwardedWhat(node *Node) string {
+	if node.IsElement() {
+		return "element '" + node.Name + "'"
+	}
+
+	return "text"
+}
+
+func lintTrailingWhitespace(l *linter, doc *Document) {
+	for i, line := range doc.lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		trimmed := strings.TrimRight(line, " \t")
+		if len(trimmed) == len(line) {
+			continue
+		}
+
+		where := protocol.Range{
+			Start: protocol.Position{Line: uint32(i), Character: runeCount(trimmed)},
+			End:   protocol.Position{Line: uint32(i), Character: runeCount(line)},
+		}
+
+		l.problem(where, []Fix{removeFix("Remove trailing whitespace", where)}, "trailing whitespace")
+	}
+}
+
+func lintDeepNesting(l *linter, doc *Document) {
+	maxDepth := l.config.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+
+	var visit func(node *Node, depth int)
+
+	visit = func(node *Node, depth int) {
+		for _, child := range node.Elements() {
+			if depth+1 > maxDepth {
+				// Everything inside is nested too deep as well, reporting the outermost element is enough.
+				l.problem(child.NameRange, nil, "'%s' is nested %d levels deep, more than the maximum of %d", child.Name, depth+1, maxDepth)
+
+				continue
+			}
+
+			visit(child, depth+1)
+		}
+	}
+
+	visit(doc.Root, 0)
+}
@@ -0,0 +1,124 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"strings"
+	"testing"
+)
+
+// nested returns depth elements nested into each other, with text in the innermost one.
+func nested(depth int) string {
+	return strings.Repeat("#e {", depth) + "x" + strings.Repeat("}", depth) + "\n"
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		rule    string
+		content string
+		// count is how many problems the rule finds in the content.
+		count int
+	}{
+		{"duplicate-attribute", "#a @key{1} @Key{2} {x}\n", 1},
+		{"empty-block", "#a {}\n#b {x}\n", 1},
+		{"mixed-grammar", "#a {x}\n#! b {\"y\"}\n", 1},
+		{"forward-outside-block", "#a {##d {q}}\n#b {x}\n", 1},
+		{"trailing-whitespace", "#a {x}  \n#b {y}\t\n#c {z}\n", 2},
+		{"deep-nesting", nested(defaultMaxDepth + 1), 1},
+	}
+
+	overrides := []struct {
+		setting  string
+		severity protocol.DiagnosticSeverity
+	}{
+		{"", 0},
+		{"error", protocol.SeverityError},
+		{"hint", protocol.SeverityHint},
+		{"off", 0},
+	}
+
+	for _, tt := range tests {
+		var rule *LintRule
+
+		for _, r := range LintRules {
+			if r.ID == tt.rule {
+				rule = r
+			}
+		}
+
+		if rule == nil {
+			t.Fatalf("no rule %s", tt.rule)
+		}
+
+		doc := ParseDocument(File{Uri: "file:///test.dyml", Content: tt.content})
+
+		for _, override := range overrides {
+			name := tt.rule + "/" + override.setting
+			if override.setting == "" {
+				name = tt.rule + "/default"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				config := LintConfig{Rules: map[string]string{}}
+				if override.setting != "" {
+					config.Rules[tt.rule] = override.setting
+				}
+
+				want, severity := tt.count, override.severity
+				if override.setting == "off" {
+					want = 0
+				}
+
+				if severity == 0 {
+					severity = rule.Severity
+				}
+
+				var got []protocol.Diagnostic
+
+				for _, problem := range Lint(doc, config) {
+					if problem.Diagnostic.Code == tt.rule {
+						got = append(got, problem.Diagnostic)
+					}
+				}
+
+				if len(got) != want {
+					t.Fatalf("got %d problems, want %d: %v", len(got), want, got)
+				}
+
+				for _, d := range got {
+					if d.Severity != severity {
+						t.Errorf("got severity %v, want %v", d.Severity, severity)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLintMaxDepth(t *testing.T) {
+	tests := []struct {
+		maxDepth int
+		depth    int
+		count    int
+	}{
+		{0, defaultMaxDepth, 0},
+		{0, defaultMaxDepth + 1, 1},
+		{2, 2, 0},
+		{2, 3, 1},
+	}
+
+	for _, tt := range tests {
+		doc := ParseDocument(File{Uri: "file:///test.dyml", Content: nested(tt.depth)})
+
+		count := 0
+
+		for _, problem := range Lint(doc, LintConfig{MaxDepth: tt.maxDepth}) {
+			if problem.Diagnostic.Code == "deep-nesting" {
+				count++
+			}
+		}
+
+		if count != tt.count {
+			t.Errorf("maxDepth %d, depth %d: got %d problems, want %d", tt.maxDepth, tt.depth, count, tt.count)
+		}
+	}
+}
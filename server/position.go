@@ -0,0 +1,52 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+
+	"github.com/golangee/dyml/token"
+)
+
+// toPosition converts a dyml position to a LSP position.
+// Subtract 1 since dyml has 1 based lines and columns, but LSP wants 0 based.
+// Positions of tokens, that do not appear in the source, are mapped to the start of the document.
+func toPosition(pos token.Pos) protocol.Position {
+	if pos.Line < 1 || pos.Col < 1 {
+		return protocol.Position{}
+	}
+
+	return protocol.Position{
+		Line:      uint32(pos.Line) - 1,
+		Character: uint32(pos.Col) - 1,
+	}
+}
+
+// toRange converts a dyml position range to a LSP range.
+func toRange(node token.Node) protocol.Range {
+	return protocol.Range{
+		Start: toPosition(node.Begin()),
+		End:   toPosition(node.End()),
+	}
+}
+
+// positionBefore returns true if a is strictly before b.
+func positionBefore(a, b protocol.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+
+	return a.Character < b.Character
+}
+
+// maxPosition returns the later of both positions.
+func maxPosition(a, b protocol.Position) protocol.Position {
+	if positionBefore(a, b) {
+		return b
+	}
+
+	return a
+}
+
+// rangeContains returns true if the position is inside the range, including both ends.
+func rangeContains(r protocol.Range, pos protocol.Position) bool {
+	return !positionBefore(pos, r.Start) && !positionBefore(r.End, pos)
+}
@@ -0,0 +1,114 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"time"
+)
+
+// previewDelay is how long a document must stay unchanged, before its preview is updated.
+// Encoding on every keystroke would only produce XML that is outdated right away.
+const previewDelay = 300 * time.Millisecond
+
+// PreviewSubscribeParams are the parameters of custom/preview/subscribe.
+type PreviewSubscribeParams struct {
+	// TextDocument is the document to keep the XML up to date for.
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	// Options replace the xml settings for all updates, if they are given.
+	Options *XMLOptions `json:"options,omitempty"`
+}
+
+// PreviewUnsubscribeParams are the parameters of custom/preview/unsubscribe.
+type PreviewUnsubscribeParams struct {
+	// TextDocument is the document to stop sending updates for.
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// PreviewUpdateParams are the parameters of the custom/preview/update notification.
+// They are the result of custom/encodeXML for the document with the URI.
+type PreviewUpdateParams struct {
+	// URI is the document the XML was encoded from.
+	URI protocol.DocumentURI `json:"uri"`
+	EncodeXMLResult
+}
+
+// previewSubscription is a document the client keeps an XML preview of.
+type previewSubscription struct {
+	options *XMLOptions
+	// sourceMap is the source map of the XML the client was sent last.
+	sourceMap []SourceMapping
+	// timer updates the preview once the document stopped changing, it is nil if no update is pending.
+	timer *time.Timer
+}
+
+// PreviewSubscribe encodes a document as XML and sends custom/preview/update with new XML after each change,
+// until the client unsubscribes or closes the document. Subscribing again replaces the options.
+func (s *Server) PreviewSubscribe(params *PreviewSubscribeParams) EncodeXMLResult {
+	uri := params.TextDocument.URI
+
+	s.stopPreview(uri)
+
+	result := s.EncodeXML(&EncodeXMLParams{
+		TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{TextDocumentIdentifier: params.TextDocument},
+		Options:      params.Options,
+	})
+	s.previews[uri] = &previewSubscription{options: params.Options, sourceMap: result.SourceMap}
+
+	return result
+}
+
+// PreviewUnsubscribe stops sending updates for a document.
+func (s *Server) PreviewUnsubscribe(params *PreviewUnsubscribeParams) interface{} {
+	s.stopPreview(params.TextDocument.URI)
+
+	return nil
+}
+
+// stopPreview drops the subscription of a document, with its pending update.
+func (s *Server) stopPreview(uri protocol.DocumentURI) {
+	if sub, ok := s.previews[uri]; ok && sub.timer != nil {
+		sub.timer.Stop()
+	}
+
+	delete(s.previews, uri)
+}
+
+// schedulePreview updates the preview of a document, if it has one, after it did not change for previewDelay.
+// The update runs on the server like other background work, when the timer went off.
+func (s *Server) schedulePreview(uri protocol.DocumentURI) {
+	sub, ok := s.previews[uri]
+	if !ok {
+		return
+	}
+
+	if sub.timer != nil {
+		sub.timer.Stop()
+	}
+
+	events := s.events
+
+	var timer *time.Timer
+	timer = time.AfterFunc(previewDelay, func() {
+		events <- func() {
+			// Stopping the timer does not help once it went off, so updates that were replaced
+			// by a newer one or belong to a subscription that ended are dropped here.
+			if s.previews[uri] != sub || sub.timer != timer {
+				return
+			}
+
+			sub.timer = nil
+			s.sendPreview(uri, sub)
+		}
+	})
+	sub.timer = timer
+}
+
+// sendPreview encodes a document as XML and sends it to the client.
+func (s *Server) sendPreview(uri protocol.DocumentURI, sub *previewSubscription) {
+	result := s.EncodeXML(&EncodeXMLParams{
+		TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}},
+		Options:      sub.options,
+	})
+	sub.sourceMap = result.SourceMap
+
+	_ = SendNotification("custom/preview/update", PreviewUpdateParams{URI: uri, EncodeXMLResult: result})
+}
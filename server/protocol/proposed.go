@@ -0,0 +1,124 @@
+package protocol
+
+// This file holds types of LSP 3.17 that are missing in tsprotocol.go or are incomplete there,
+// as it was generated while they were only proposed.
+
+// DocumentDiagnosticReportKind tells whether a diagnostic report is complete or unchanged.
+type DocumentDiagnosticReportKind string
+
+const (
+	// DiagnosticFull reports contain all diagnostics of a document.
+	DiagnosticFull DocumentDiagnosticReportKind = "full"
+	// DiagnosticUnchanged reports tell that the diagnostics did not change since the previous result.
+	DiagnosticUnchanged DocumentDiagnosticReportKind = "unchanged"
+)
+
+// DiagnosticOptions describe how the server answers requests for diagnostics.
+type DiagnosticOptions struct {
+	Identifier string `json:"identifier,omitempty"`
+	// InterFileDependencies is true if changes in one document can change the diagnostics of others.
+	InterFileDependencies bool `json:"interFileDependencies"`
+	// WorkspaceDiagnostics is true if the server answers workspace/diagnostic requests.
+	WorkspaceDiagnostics bool `json:"workspaceDiagnostics"`
+}
+
+// FullDocumentDiagnosticReport contains all diagnostics of a document.
+type FullDocumentDiagnosticReport struct {
+	Kind DocumentDiagnosticReportKind `json:"kind"`
+	// ResultID is sent by the client with its next request for the document.
+	ResultID string       `json:"resultId,omitempty"`
+	Items    []Diagnostic `json:"items"`
+}
+
+// UnchangedDocumentDiagnosticReport tells that the diagnostics of a document are the same
+// as in the report with the result id.
+type UnchangedDocumentDiagnosticReport struct {
+	Kind     DocumentDiagnosticReportKind `json:"kind"`
+	ResultID string                       `json:"resultId"`
+}
+
+// WorkspaceFullDocumentDiagnosticReport is a FullDocumentDiagnosticReport in a workspace report.
+type WorkspaceFullDocumentDiagnosticReport struct {
+	FullDocumentDiagnosticReport
+	URI DocumentURI `json:"uri"`
+	// Version is the version of the document the diagnostics are for, or nil if it is not open.
+	Version *int32 `json:"version"`
+}
+
+// WorkspaceUnchangedDocumentDiagnosticReport is an UnchangedDocumentDiagnosticReport in a workspace report.
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	UnchangedDocumentDiagnosticReport
+	URI DocumentURI `json:"uri"`
+	// Version is the version of the document the diagnostics are for, or nil if it is not open.
+	Version *int32 `json:"version"`
+}
+
+// WorkspaceDiagnosticReport317 contains the reports for all documents in the workspace.
+type WorkspaceDiagnosticReport317 struct {
+	Items []interface{} /*WorkspaceFullDocumentDiagnosticReport | WorkspaceUnchangedDocumentDiagnosticReport*/ `json:"items"`
+}
+
+// ServerCapabilities317 adds the capabilities of LSP 3.17 to ServerCapabilities.
+type ServerCapabilities317 struct {
+	ServerCapabilities
+	DiagnosticProvider *DiagnosticOptions `json:"diagnosticProvider,omitempty"`
+}
+
+// InitializeResult317 is an InitializeResult with the capabilities of LSP 3.17.
+type InitializeResult317 struct {
+	InitializeResult
+	Capabilities ServerCapabilities317 `json:"capabilities"`
+}
+
+// DiagnosticWorkspaceClientCapabilities describe what the client supports of workspace diagnostics.
+type DiagnosticWorkspaceClientCapabilities struct {
+	// RefreshSupport is true if the client pulls diagnostics again on workspace/diagnostic/refresh.
+	RefreshSupport bool `json:"refreshSupport,omitempty"`
+}
+
+// WorkspaceEditClientCapabilities317 tells whether the client supports change annotations at all,
+// which WorkspaceEditClientCapabilities cannot tell from a client that does not send them.
+type WorkspaceEditClientCapabilities317 struct {
+	WorkspaceEditClientCapabilities
+	ChangeAnnotationSupport *struct {
+		GroupsOnLabel bool `json:"groupsOnLabel,omitempty"`
+	} `json:"changeAnnotationSupport,omitempty"`
+}
+
+// WorkspaceClientCapabilities317 adds the workspace capabilities of LSP 3.17 to Workspace2Gn.
+type WorkspaceClientCapabilities317 struct {
+	Workspace2Gn
+	WorkspaceEdit WorkspaceEditClientCapabilities317    `json:"workspaceEdit,omitempty"`
+	Diagnostics   DiagnosticWorkspaceClientCapabilities `json:"diagnostics,omitempty"`
+}
+
+// ClientCapabilities317 adds the capabilities of LSP 3.17 to ClientCapabilities.
+type ClientCapabilities317 struct {
+	ClientCapabilities
+	Workspace WorkspaceClientCapabilities317 `json:"workspace,omitempty"`
+}
+
+// InitializeParams317 is an InitializeParams with the capabilities of LSP 3.17.
+type InitializeParams317 struct {
+	InitializeParams
+	Capabilities ClientCapabilities317 `json:"capabilities"`
+}
+
+// NullableVersionedTextDocumentIdentifier is an OptionalVersionedTextDocumentIdentifier,
+// whose version can actually be null for documents that are not open.
+type NullableVersionedTextDocumentIdentifier struct {
+	URI     DocumentURI `json:"uri"`
+	Version *int32      `json:"version"`
+}
+
+// AnnotatedTextDocumentEdit is a TextDocumentEdit whose edits have change annotations.
+type AnnotatedTextDocumentEdit struct {
+	TextDocument NullableVersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []AnnotatedTextEdit                     `json:"edits"`
+}
+
+// AnnotatedWorkspaceEdit is a WorkspaceEdit with change annotations, whose type is wrong in WorkspaceEdit.
+type AnnotatedWorkspaceEdit struct {
+	DocumentChanges   []AnnotatedTextDocumentEdit                     `json:"documentChanges"`
+	ChangeAnnotations map[ChangeAnnotationIdentifier]ChangeAnnotation `json:"changeAnnotations"`
+}
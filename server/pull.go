@@ -0,0 +1,102 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+)
+
+// DocumentDiagnostic answers a client that pulls the diagnostics of a document.
+// The report is unchanged, if the diagnostics are the same as in the client's previous result.
+func (s *Server) DocumentDiagnostic(params *protocol.DocumentDiagnosticParams) interface{} {
+	s.startPullingDiagnostics()
+
+	content, err := s.readFile(params.TextDocument.URI)
+	if err != nil {
+		return protocol.FullDocumentDiagnosticReport{Kind: protocol.DiagnosticFull, Items: []protocol.Diagnostic{}}
+	}
+
+	file := File{Uri: params.TextDocument.URI, Content: content}
+
+	diagnostics := s.diagnostics(file)
+	id := resultID(diagnostics)
+
+	if id == params.PreviousResultID {
+		return protocol.UnchangedDocumentDiagnosticReport{Kind: protocol.DiagnosticUnchanged, ResultID: id}
+	}
+
+	return protocol.FullDocumentDiagnosticReport{Kind: protocol.DiagnosticFull, ResultID: id, Items: diagnostics}
+}
+
+// WorkspaceDiagnostic answers a client that pulls the diagnostics of all open documents and files in the workspace.
+func (s *Server) WorkspaceDiagnostic(params *protocol.WorkspaceDiagnosticParams) protocol.WorkspaceDiagnosticReport317 {
+	s.startPullingDiagnostics()
+
+	previous := make(map[protocol.DocumentURI]string)
+	for _, prev := range params.PreviousResultIds {
+		previous[prev.URI] = prev.Value
+	}
+
+	report := protocol.WorkspaceDiagnosticReport317{Items: []interface{}{}}
+
+	for _, file := range s.allFiles() {
+		uri := file.Uri
+
+		// Only open documents have a version.
+		var version *int32
+		if _, ok := s.files[uri]; ok {
+			v := file.Version
+			version = &v
+		}
+
+		diagnostics := s.diagnostics(file)
+		id := resultID(diagnostics)
+
+		if id == previous[uri] {
+			report.Items = append(report.Items, protocol.WorkspaceUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: protocol.UnchangedDocumentDiagnosticReport{Kind: protocol.DiagnosticUnchanged, ResultID: id},
+				URI:                               uri,
+				Version:                           version,
+			})
+
+			continue
+		}
+
+		report.Items = append(report.Items, protocol.WorkspaceFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: protocol.FullDocumentDiagnosticReport{Kind: protocol.DiagnosticFull, ResultID: id, Items: diagnostics},
+			URI:                          uri,
+			Version:                      version,
+		})
+	}
+
+	return report
+}
+
+// startPullingDiagnostics stops pushing diagnostics, when the client pulls them for the first time.
+// Diagnostics we pushed before are removed, as the client would show them in addition to the pulled ones.
+func (s *Server) startPullingDiagnostics() {
+	if s.pullDiagnostics {
+		return
+	}
+
+	s.pullDiagnostics = true
+
+	for _, file := range s.allFiles() {
+		_ = SendNotification("textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+			URI:         file.Uri,
+			Diagnostics: []protocol.Diagnostic{},
+		})
+	}
+}
+
+// resultID identifies diagnostics by their content. This tells us if the diagnostics a client
+// already has are still the same, without remembering what we sent.
+func resultID(diagnostics []protocol.Diagnostic) string {
+	buf, _ := json.Marshal(diagnostics)
+
+	h := fnv.New64a()
+	_, _ = h.Write(buf)
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
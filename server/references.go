@@ -0,0 +1,122 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+)
+
+// nameTarget is the name of an element or the key of an attribute. The same name at the same path
+// of elements is considered to be the same thing, also in other documents.
+type nameTarget struct {
+	// path is the path of the element, or of the element the attribute belongs to.
+	path string
+	// key is only set for attributes.
+	key string
+	// where is the range of the name the target was found at.
+	where protocol.Range
+}
+
+// nameTargetAt returns the element name or attribute key at the position.
+func nameTargetAt(doc *Document, pos protocol.Position) (nameTarget, bool) {
+	if node, attr := doc.AttributeAt(pos); attr != nil {
+		return nameTarget{path: node.Path(), key: attr.Key, where: attr.KeyRange}, true
+	}
+
+	if node := doc.NodeAt(pos); node != nil {
+		return nameTarget{path: node.Path(), where: node.NameRange}, true
+	}
+
+	return nameTarget{}, false
+}
+
+// visit calls found for all elements in the document with the same name as the target, or for all attributes
+// with the same key, together with their element. attr is nil for elements.
+// Text and comments are never included, even if they contain the same word.
+func (t nameTarget) visit(doc *Document, found func(node *Node, attr *Attribute)) {
+	doc.Root.Walk(func(node *Node) bool {
+		if !node.IsElement() || node.IsRoot() || node.Path() != t.path {
+			return true
+		}
+
+		if t.key == "" {
+			found(node, nil)
+
+			return true
+		}
+
+		for _, attr := range node.Attributes {
+			if attr.Key == t.key {
+				found(node, attr)
+			}
+		}
+
+		return true
+	})
+}
+
+// occurrences returns the ranges of all names in the document that are the same as the target.
+func (t nameTarget) occurrences(doc *Document) []protocol.Range {
+	var ranges []protocol.Range
+
+	t.visit(doc, func(node *Node, attr *Attribute) {
+		if attr == nil {
+			ranges = append(ranges, node.NameRange)
+		} else {
+			ranges = append(ranges, attr.KeyRange)
+		}
+	})
+
+	return ranges
+}
+
+// DocumentHighlight highlights all occurrences of the element name or attribute key under the cursor in the document.
+func (s *Server) DocumentHighlight(params *protocol.DocumentHighlightParams) []protocol.DocumentHighlight {
+	highlights := []protocol.DocumentHighlight{}
+
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return highlights
+	}
+
+	doc := ParseDocument(file)
+
+	target, ok := nameTargetAt(doc, params.Position)
+	if !ok {
+		return highlights
+	}
+
+	for _, where := range target.occurrences(doc) {
+		highlights = append(highlights, protocol.DocumentHighlight{Range: where, Kind: protocol.Text})
+	}
+
+	return highlights
+}
+
+// References finds all occurrences of the element name or attribute key under the cursor in the workspace.
+// For identifiers, all references to them are found instead.
+func (s *Server) References(params *protocol.ReferenceParams) []protocol.Location {
+	locations := []protocol.Location{}
+
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return locations
+	}
+
+	doc := ParseDocument(file)
+
+	if ref, ok := s.crossRefAt(doc, params.Position); ok {
+		return s.crossReferences(ref, params.Context.IncludeDeclaration)
+	}
+
+	target, ok := nameTargetAt(doc, params.Position)
+	if !ok {
+		return locations
+	}
+
+	for _, file := range s.allFiles() {
+		for _, where := range target.occurrences(ParseDocument(file)) {
+			locations = append(locations, protocol.Location{URI: file.Uri, Range: where})
+		}
+	}
+
+	return locations
+}
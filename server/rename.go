@@ -0,0 +1,212 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scopes of a rename, as chosen by the client or set with the renameScope setting.
+const (
+	// renameNode only renames the element or attribute under the cursor.
+	renameNode = "node"
+	// renameDocument renames all elements or attributes at the same path in the document.
+	renameDocument = "document"
+	// renameWorkspace renames all elements or attributes at the same path in all files of the workspace.
+	renameWorkspace = "workspace"
+)
+
+// validName matches the names of elements and attributes, which are the same in G1 and G2.
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_]+(\.[a-zA-Z0-9_]+)*$`)
+
+// renameTargetAt returns the element name or attribute key at the position, if it can be renamed.
+// Names the document does not contain literally, like the 'ret' element of a G2 return arrow, cannot.
+func renameTargetAt(doc *Document, pos protocol.Position) (nameTarget, bool) {
+	target, ok := nameTargetAt(doc, pos)
+	if !ok {
+		return target, false
+	}
+
+	name := target.key
+	if name == "" {
+		name = doc.NodeAt(pos).Name
+	}
+
+	return target, doc.textIn(target.where) == name
+}
+
+// PrepareRename tells the client the range of the name under the cursor, or nil if there is nothing to rename.
+func (s *Server) PrepareRename(params *protocol.PrepareRenameParams) *protocol.Range {
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	target, ok := renameTargetAt(ParseDocument(file), params.Position)
+	if !ok {
+		return nil
+	}
+
+	return &target.where
+}
+
+// RenameParams are the parameters of textDocument/rename, with the scope the client may choose for each rename.
+type RenameParams struct {
+	protocol.RenameParams
+	// Scope is "node", "document" or "workspace". The renameScope setting is used, if it is empty.
+	Scope string `json:"scope,omitempty"`
+}
+
+// renamed is an element or attribute that is renamed.
+type renamed struct {
+	file File
+	node *Node
+	// attr is nil if the element is renamed.
+	attr *Attribute
+}
+
+// where returns the range of the name that is replaced.
+func (r renamed) where() protocol.Range {
+	if r.attr == nil {
+		return r.node.NameRange
+	}
+
+	return r.attr.KeyRange
+}
+
+// preview shows the element after the rename in G1 and G2, without its children.
+func (r renamed) preview(newName string) string {
+	shallow := &Node{Name: r.node.Name}
+	if r.attr == nil {
+		shallow.Name = newName
+	}
+
+	for _, attr := range r.node.Attributes {
+		key := attr.Key
+		if attr == r.attr {
+			key = newName
+		}
+
+		shallow.Attributes = append(shallow.Attributes, &Attribute{Key: key, Value: attr.Value})
+	}
+
+	g1, g2 := &dymlWriter{}, &dymlWriter{g2: true}
+	g1.g1Element(shallow, 0)
+	g2.g2Element(shallow, 0, false)
+
+	return "G1: " + strings.TrimSuffix(g1.sb.String(), " {}\n") + "  G2: " + strings.TrimSuffix(g2.sb.String(), ",\n")
+}
+
+// Rename renames the element or attribute under the cursor. Depending on the scope, only this one is renamed,
+// all at the same path in the document or all at the same path in the workspace. Only names are replaced,
+// so brackets, '#' and '@' stay as they are in both G1 and G2.
+//
+// Clients that support change annotations get edits that need to be confirmed, so they show a preview.
+// Each edit is described with its element in G1 and G2, as it looks after the rename.
+func (s *Server) Rename(params *RenameParams) interface{} {
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	doc := ParseDocument(file)
+
+	target, ok := renameTargetAt(doc, params.Position)
+	if !ok {
+		return nil
+	}
+
+	if !validName.MatchString(params.NewName) {
+		s.showMessage(protocol.Error, fmt.Sprintf("'%s' is not a valid name, only letters, digits and '_' are allowed", params.NewName))
+
+		return nil
+	}
+
+	scope := params.Scope
+	if scope == "" {
+		scope = s.config.RenameScope
+	}
+
+	var names []renamed
+
+	switch scope {
+	case renameNode:
+		node, attr := doc.AttributeAt(params.Position)
+		if attr == nil {
+			node = doc.NodeAt(params.Position)
+		}
+
+		names = append(names, renamed{file: file, node: node, attr: attr})
+	case renameDocument:
+		target.visit(doc, func(node *Node, attr *Attribute) {
+			names = append(names, renamed{file: file, node: node, attr: attr})
+		})
+	default:
+		for _, file := range s.allFiles() {
+			file := file
+
+			target.visit(ParseDocument(file), func(node *Node, attr *Attribute) {
+				names = append(names, renamed{file: file, node: node, attr: attr})
+			})
+		}
+	}
+
+	edits := s.capabilities.Workspace.WorkspaceEdit
+	if !edits.DocumentChanges || edits.ChangeAnnotationSupport == nil {
+		edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{}}
+		for _, name := range names {
+			uri := string(name.file.Uri)
+			edit.Changes[uri] = append(edit.Changes[uri], protocol.TextEdit{Range: name.where(), NewText: params.NewName})
+		}
+
+		return edit
+	}
+
+	oldName := doc.textIn(target.where)
+	edit := &protocol.AnnotatedWorkspaceEdit{
+		DocumentChanges:   []protocol.AnnotatedTextDocumentEdit{},
+		ChangeAnnotations: map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation{},
+	}
+
+	// Elements that look the same after the rename share their annotation.
+	annotations := make(map[string]protocol.ChangeAnnotationIdentifier)
+
+	for _, name := range names {
+		preview := name.preview(params.NewName)
+
+		id, ok := annotations[preview]
+		if !ok {
+			id = strconv.Itoa(len(annotations))
+			annotations[preview] = id
+			edit.ChangeAnnotations[id] = protocol.ChangeAnnotation{
+				Label:             fmt.Sprintf("Rename '%s' to '%s'", oldName, params.NewName),
+				NeedsConfirmation: true,
+				Description:       preview,
+			}
+		}
+
+		last := len(edit.DocumentChanges) - 1
+		if last < 0 || edit.DocumentChanges[last].TextDocument.URI != name.file.Uri {
+			documentEdit := protocol.AnnotatedTextDocumentEdit{}
+			documentEdit.TextDocument.URI = name.file.Uri
+
+			// Only open documents have a version, the others are changed as they are on disk.
+			if _, open := s.files[name.file.Uri]; open {
+				version := name.file.Version
+				documentEdit.TextDocument.Version = &version
+			}
+
+			edit.DocumentChanges = append(edit.DocumentChanges, documentEdit)
+			last++
+		}
+
+		edit.DocumentChanges[last].Edits = append(edit.DocumentChanges[last].Edits, protocol.AnnotatedTextEdit{
+			AnnotationID: id,
+			TextEdit:     protocol.TextEdit{Range: name.where(), NewText: params.NewName},
+		})
+	}
+
+	return edit
+}
@@ -11,6 +11,15 @@ type Response struct {
 	Result interface{} `json:"result"`
 }
 
+type Request struct {
+	Id     float64     `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+// requestId is the ID of the last request we sent to the client.
+var requestId float64
+
 type Notification struct {
 	Method string      `json:"method"`
 	Params interface{} `json:"params"`
@@ -50,3 +59,23 @@ func SendNotification(method string, notification interface{}) error {
 
 	return nil
 }
+
+// Send a request to the client. We do not wait for the client's response, which is ignored.
+func SendRequest(method string, params interface{}) error {
+	requestId++
+
+	responseBytes, err := json.Marshal(Request{
+		Id:     requestId,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	responseData := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(responseBytes), responseBytes)
+	log.Printf("Sending request: %s", responseBytes)
+	fmt.Print(responseData)
+
+	return nil
+}
@@ -0,0 +1,491 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Schema describes the structure of DYML documents. Schemas are written in DYML as well:
+//
+//	#schema {
+//	    #element @name{config} @min{1} @max{1} @text{forbidden} {
+//	        #attribute @name{version} @type{number} @required{true}
+//	        #attribute @name{mode} @enum{dev,prod}
+//	        #element @name{server} @max{*} {
+//	            #attribute @name{host} @required{true}
+//	        }
+//	    }
+//	}
+//
+// Elements and attributes can be described with @doc and marked with @deprecated, which holds
+// a message or "true".
+// Elements may occur between min (default 0) and max (default unbounded, "*") times in their parent.
+// Their text content is "allowed" (default), "forbidden" or "required". Elements with @open{true}
+// accept any attribute and child element that is not described.
+// Attributes are of type "string" (default), "number" or "bool", or one of the comma separated
+// values in @enum. Attributes of type "id" define identifiers, which attributes of type "ref" reference.
+type Schema struct {
+	URI protocol.DocumentURI
+	// Root describes the implicit root element, its children are the allowed top level elements.
+	Root *ElementSchema
+}
+
+// ElementSchema describes an element and its content.
+type ElementSchema struct {
+	Name string
+	Doc  string
+	// Deprecated is a message telling what to use instead, or "true". It is empty if the element is not deprecated.
+	Deprecated string
+	// Min and Max limit how often this element may occur in its parent. Max is -1 for unbounded.
+	Min, Max   int
+	Text       TextRule
+	Open       bool
+	Attributes []*AttributeSchema
+	Children   []*ElementSchema
+}
+
+// AttributeSchema describes an attribute of an element.
+type AttributeSchema struct {
+	Name string
+	Doc  string
+	// Deprecated works like ElementSchema.Deprecated.
+	Deprecated string
+	Type       AttributeType
+	Enum       []string
+	Required   bool
+}
+
+// TextRule tells if an element may contain text.
+type TextRule string
+
+const (
+	TextAllowed   TextRule = "allowed"
+	TextForbidden TextRule = "forbidden"
+	TextRequired  TextRule = "required"
+)
+
+// AttributeType is the type of value an attribute has.
+type AttributeType string
+
+const (
+	TypeString AttributeType = "string"
+	TypeNumber AttributeType = "number"
+	TypeBool   AttributeType = "bool"
+	TypeEnum   AttributeType = "enum"
+	TypeID     AttributeType = "id"
+	TypeRef    AttributeType = "ref"
+)
+
+// Child returns the schema of the child element with the given name or nil.
+func (e *ElementSchema) Child(name string) *ElementSchema {
+	for _, child := range e.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// Attribute returns the schema of the attribute with the given name or nil.
+func (e *ElementSchema) Attribute(name string) *AttributeSchema {
+	for _, attr := range e.Attributes {
+		if attr.Name == name {
+			return attr
+		}
+	}
+
+	return nil
+}
+
+// Describe returns a markdown description of the element for the user.
+func (e *ElementSchema) Describe() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("**%s**\n\n", e.Name))
+
+	if e.Deprecated != "" {
+		sb.WriteString(fmt.Sprintf("*%s*\n\n", deprecationMessage("this element", e.Deprecated)))
+	}
+
+	if e.Doc != "" {
+		sb.WriteString(e.Doc + "\n\n")
+	}
+
+	max := "*"
+	if e.Max >= 0 {
+		max = strconv.Itoa(e.Max)
+	}
+
+	sb.WriteString(fmt.Sprintf("Occurs %d to %s times, text is %s.", e.Min, max, e.Text))
+
+	return sb.String()
+}
+
+// Describe returns a markdown description of the attribute for the user.
+func (a *AttributeSchema) Describe() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("**@%s** `%s`", a.Name, a.Detail()))
+
+	if a.Deprecated != "" {
+		sb.WriteString(fmt.Sprintf("\n\n*%s*", deprecationMessage("this attribute", a.Deprecated)))
+	}
+
+	if a.Doc != "" {
+		sb.WriteString("\n\n" + a.Doc)
+	}
+
+	return sb.String()
+}
+
+// Detail returns a short summary of the attribute's type.
+func (a *AttributeSchema) Detail() string {
+	detail := string(a.Type)
+	if a.Type == TypeEnum {
+		detail = strings.Join(a.Enum, " | ")
+	}
+
+	if a.Required {
+		detail += ", required"
+	}
+
+	return detail
+}
+
+// Values returns all valid values, if there is a limited set of them.
+func (a *AttributeSchema) Values() []string {
+	switch a.Type {
+	case TypeBool:
+		return []string{"true", "false"}
+	case TypeEnum:
+		return a.Enum
+	default:
+		return nil
+	}
+}
+
+// Check returns a description of what is wrong with the value or an empty string if it is valid.
+func (a *AttributeSchema) Check(value string) string {
+	switch a.Type {
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("attribute '%s' must be a number", a.Name)
+		}
+	case TypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Sprintf("attribute '%s' must be 'true' or 'false'", a.Name)
+		}
+	case TypeEnum:
+		for _, allowed := range a.Enum {
+			if value == allowed {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("attribute '%s' must be one of: %s", a.Name, strings.Join(a.Enum, ", "))
+	}
+
+	return ""
+}
+
+// IsSchema returns true if the document is a schema, i.e. it has a single 'schema' element.
+func IsSchema(doc *Document) bool {
+	elements := doc.Root.Elements()
+
+	return len(elements) == 1 && elements[0].Name == "schema"
+}
+
+// ParseSchema reads a schema from a DYML document.
+func ParseSchema(file File) (*Schema, error) {
+	doc := ParseDocument(file)
+	if doc.Err != nil {
+		return nil, doc.Err
+	}
+
+	if !IsSchema(doc) {
+		return nil, fmt.Errorf("a schema must contain exactly one 'schema' element")
+	}
+
+	elements := doc.Root.Elements()
+
+	root := &ElementSchema{
+		Name: "root",
+		Max:  -1,
+		Text: TextAllowed,
+	}
+
+	for _, child := range elements[0].Elements() {
+		if child.Name != "element" {
+			return nil, schemaError(child, "expected 'element', got '%s'", child.Name)
+		}
+
+		element, err := parseElementSchema(child)
+		if err != nil {
+			return nil, err
+		}
+
+		root.Children = append(root.Children, element)
+	}
+
+	return &Schema{
+		URI:  file.Uri,
+		Root: root,
+	}, nil
+}
+
+func parseElementSchema(node *Node) (*ElementSchema, error) {
+	element := &ElementSchema{
+		Max:  -1,
+		Text: TextAllowed,
+	}
+
+	for _, attr := range node.Attributes {
+		var err error
+
+		switch attr.Key {
+		case "name":
+			element.Name = attr.Value
+		case "min":
+			element.Min, err = strconv.Atoi(attr.Value)
+		case "max":
+			if attr.Value != "*" {
+				element.Max, err = strconv.Atoi(attr.Value)
+			}
+		case "text":
+			element.Text = TextRule(attr.Value)
+			if element.Text != TextAllowed && element.Text != TextForbidden && element.Text != TextRequired {
+				return nil, schemaError(node, "text must be 'allowed', 'forbidden' or 'required'")
+			}
+		case "open":
+			element.Open, err = strconv.ParseBool(attr.Value)
+		case "doc":
+			element.Doc = attr.Value
+		case "deprecated":
+			element.Deprecated = attr.Value
+		default:
+			return nil, schemaError(node, "unknown attribute '%s' of 'element'", attr.Key)
+		}
+
+		if err != nil {
+			return nil, schemaError(node, "invalid value for '%s': %s", attr.Key, attr.Value)
+		}
+	}
+
+	if element.Name == "" {
+		return nil, schemaError(node, "'element' requires a name")
+	}
+
+	for _, child := range node.Elements() {
+		switch child.Name {
+		case "element":
+			childElement, err := parseElementSchema(child)
+			if err != nil {
+				return nil, err
+			}
+
+			element.Children = append(element.Children, childElement)
+		case "attribute":
+			attribute, err := parseAttributeSchema(child)
+			if err != nil {
+				return nil, err
+			}
+
+			element.Attributes = append(element.Attributes, attribute)
+		default:
+			return nil, schemaError(child, "expected 'element' or 'attribute', got '%s'", child.Name)
+		}
+	}
+
+	return element, nil
+}
+
+func parseAttributeSchema(node *Node) (*AttributeSchema, error) {
+	attribute := &AttributeSchema{
+		Type: TypeString,
+	}
+
+	for _, attr := range node.Attributes {
+		var err error
+
+		switch attr.Key {
+		case "name":
+			attribute.Name = attr.Value
+		case "type":
+			attribute.Type = AttributeType(attr.Value)
+			switch attribute.Type {
+			case TypeString, TypeNumber, TypeBool, TypeID, TypeRef:
+			default:
+				return nil, schemaError(node, "type must be 'string', 'number', 'bool', 'id' or 'ref'")
+			}
+		case "enum":
+			attribute.Type = TypeEnum
+			for _, value := range strings.Split(attr.Value, ",") {
+				attribute.Enum = append(attribute.Enum, strings.TrimSpace(value))
+			}
+		case "required":
+			attribute.Required, err = strconv.ParseBool(attr.Value)
+		case "doc":
+			attribute.Doc = attr.Value
+		case "deprecated":
+			attribute.Deprecated = attr.Value
+		default:
+			return nil, schemaError(node, "unknown attribute '%s' of 'attribute'", attr.Key)
+		}
+
+		if err != nil {
+			return nil, schemaError(node, "invalid value for '%s': %s", attr.Key, attr.Value)
+		}
+	}
+
+	if attribute.Name == "" {
+		return nil, schemaError(node, "'attribute' requires a name")
+	}
+
+	return attribute, nil
+}
+
+// schemaError creates an error that points to a node in a schema.
+func schemaError(node *Node, format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", node.NameRange.Start.Line+1, node.NameRange.Start.Character+1, fmt.Sprintf(format, args...))
+}
+
+// schemaDirective is the comment that associates a document with a schema, e.g. "#? dyml-schema config.schema.dyml".
+const schemaDirective = "dyml-schema"
+
+// schemaFor finds the schema for a document. A directive in the document takes precedence
+// over the globs in the configuration. The returned range is where the schema was referenced,
+// which is the start of the document if it was configured. A directive without exactly one
+// schema path is an error.
+func (s *Server) schemaFor(doc *Document) (protocol.DocumentURI, protocol.Range, bool, error) {
+	if args, where, ok := findDirective(doc, schemaDirective); ok {
+		if len(args) != 1 {
+			return "", where, true, fmt.Errorf("'%s' expects one schema path, got %d", schemaDirective, len(args))
+		}
+
+		return resolveURI(doc.Uri, args[0]), where, true, nil
+	}
+
+	schemaPath, folderPath, ok := s.configured(doc, s.config.Schemas)
+	if !ok {
+		return "", protocol.Range{}, false, nil
+	}
+
+	if !filepath.IsAbs(schemaPath) {
+		schemaPath = filepath.Join(folderPath, schemaPath)
+	}
+
+	return pathToURI(schemaPath), protocol.Range{}, true, nil
+}
+
+// Format writes the schema in G1 syntax, leaving out everything that has a default value.
+func (s *Schema) Format() string {
+	var sb strings.Builder
+
+	sb.WriteString("#schema {\n")
+
+	for _, element := range s.Root.Children {
+		element.format(&sb, 1)
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+func (e *ElementSchema) format(sb *strings.Builder, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	sb.WriteString(indent + "#element")
+	writeSchemaAttribute(sb, "name", e.Name, true)
+	writeSchemaAttribute(sb, "min", strconv.Itoa(e.Min), e.Min != 0)
+	writeSchemaAttribute(sb, "max", strconv.Itoa(e.Max), e.Max >= 0)
+	writeSchemaAttribute(sb, "text", string(e.Text), e.Text != TextAllowed)
+	writeSchemaAttribute(sb, "open", "true", e.Open)
+	writeSchemaAttribute(sb, "doc", e.Doc, e.Doc != "")
+	writeSchemaAttribute(sb, "deprecated", e.Deprecated, e.Deprecated != "")
+
+	if len(e.Attributes) == 0 && len(e.Children) == 0 {
+		sb.WriteString("\n")
+
+		return
+	}
+
+	sb.WriteString(" {\n")
+
+	for _, attr := range e.Attributes {
+		sb.WriteString(indent + "    #attribute")
+		writeSchemaAttribute(sb, "name", attr.Name, true)
+		writeSchemaAttribute(sb, "type", string(attr.Type), attr.Type != TypeString && attr.Type != TypeEnum)
+		writeSchemaAttribute(sb, "enum", strings.Join(attr.Enum, ","), attr.Type == TypeEnum)
+		writeSchemaAttribute(sb, "required", "true", attr.Required)
+		writeSchemaAttribute(sb, "doc", attr.Doc, attr.Doc != "")
+		writeSchemaAttribute(sb, "deprecated", attr.Deprecated, attr.Deprecated != "")
+		sb.WriteString("\n")
+	}
+
+	for _, child := range e.Children {
+		child.format(sb, depth+1)
+	}
+
+	sb.WriteString(indent + "}\n")
+}
+
+// writeSchemaAttribute writes a G1 attribute, if write is true.
+func writeSchemaAttribute(sb *strings.Builder, key, value string, write bool) {
+	if write {
+		sb.WriteString(" @" + key + "{" + g1ValueEscaper.Replace(value) + "}")
+	}
+}
+
+// ElementFor returns the schema of an element in a document, or nil if the element is not described.
+func (s *Schema) ElementFor(node *Node) *ElementSchema {
+	if node.IsRoot() {
+		return s.Root
+	}
+
+	parent := s.ElementFor(node.Parent)
+	if parent == nil {
+		return nil
+	}
+
+	return parent.Child(node.Name)
+}
+
+// deprecationMessage builds a message for a deprecated element or attribute.
+func deprecationMessage(what, deprecated string) string {
+	if deprecated == "true" {
+		return what + " is deprecated"
+	}
+
+	return what + " is deprecated: " + deprecated
+}
+
+// schemaOf finds and loads the schema for a document. It returns nil if there is none,
+// or it could not be loaded.
+func (s *Server) schemaOf(doc *Document) *Schema {
+	uri, _, ok, err := s.schemaFor(doc)
+	if !ok || err != nil {
+		return nil
+	}
+
+	schema, err := s.loadSchema(uri)
+	if err != nil {
+		return nil
+	}
+
+	return schema
+}
+
+// loadSchema reads and parses the schema at the given URI.
+func (s *Server) loadSchema(uri protocol.DocumentURI) (*Schema, error) {
+	content, err := s.readFile(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSchema(File{Uri: uri, Content: content})
+}
@@ -0,0 +1,41 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    protocol.DocumentURI
+		wantErr bool
+	}{
+		{"directive", "#? dyml-schema a.schema.dyml\n#a {x}\n", "file:///dir/a.schema.dyml", false},
+		{"followed by text", "#? dyml-schema a.schema.dyml\nSome text here\n#a {x}\n", "file:///dir/a.schema.dyml", false},
+		{"g2", "#! a {\n    // dyml-schema ../b.schema.dyml\n    \"x\"\n}\n", "file:///b.schema.dyml", false},
+		{"no path", "#? dyml-schema\n#a {x}\n", "", true},
+		{"two paths", "#? dyml-schema a.schema.dyml b.schema.dyml\n#a {x}\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			doc := ParseDocument(File{Uri: "file:///dir/doc.dyml", Content: tt.content})
+
+			uri, _, ok, err := s.schemaFor(doc)
+			if !ok {
+				t.Fatal("directive not found")
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+
+			if uri != tt.want {
+				t.Errorf("got %s, want %s", uri, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,11 @@ package dyml
 
 import (
 	"dyml-support/protocol"
+	"fmt"
+	"log"
 	"path/filepath"
 	"strings"
 
-	"github.com/golangee/dyml/encoder"
-	"github.com/golangee/dyml/parser"
 	"github.com/golangee/dyml/token"
 )
 
@@ -14,26 +14,87 @@ import (
 type Server struct {
 	// Map from Uri's to files.
 	files map[protocol.DocumentURI]File
+	// workspaceFolders are the root folders of the opened workspace.
+	workspaceFolders []protocol.DocumentURI
+	// config holds the user's settings.
+	config Config
+	// capabilities are what the client told us it supports.
+	capabilities protocol.ClientCapabilities317
+	// pullDiagnostics is true once the client asked for diagnostics, which we then stop pushing.
+	pullDiagnostics bool
+	// workspaceFiles are the DYML files in the workspace folders by their path, as they are on disk.
+	workspaceFiles map[string]File
+	// symbols are the symbols of all open files and files of the workspace by their path.
+	symbols map[string][]protocol.SymbolInformation
+	// crossRefs are the definitions and references of identifiers in all open files and files of the workspace by their path.
+	crossRefs map[string][]crossRef
+	// previews are the documents the client keeps a live XML preview of.
+	previews map[protocol.DocumentURI]*previewSubscription
+	// events receives work from the background, that has to be done on the server.
+	events chan func()
 }
 
 func NewServer() Server {
 	return Server{
-		files: make(map[protocol.DocumentURI]File),
+		files:          make(map[protocol.DocumentURI]File),
+		workspaceFiles: make(map[string]File),
+		symbols:        make(map[string][]protocol.SymbolInformation),
+		crossRefs:      make(map[string][]crossRef),
+		previews:       make(map[protocol.DocumentURI]*previewSubscription),
+		events:         make(chan func()),
 	}
 }
 
 // Handle a client's request to initialize and respond with our capabilities.
-func (s *Server) Initialize(params *protocol.InitializeParams) protocol.InitializeResult {
-	// For a perfect server we would need to check params.Capabilities to know
-	// what information the client can handle.
-	return protocol.InitializeResult{
-		Capabilities: protocol.ServerCapabilities{
-			TextDocumentSync: protocol.Full,
-			SemanticTokensProvider: protocol.SemanticTokensOptions{
-				Legend: protocol.SemanticTokensLegend{
-					TokenTypes: TokenTypes,
+func (s *Server) Initialize(params *protocol.InitializeParams317) protocol.InitializeResult317 {
+	s.capabilities = params.Capabilities
+
+	for _, folder := range params.WorkspaceFolders {
+		s.workspaceFolders = append(s.workspaceFolders, protocol.DocumentURI(folder.URI))
+	}
+
+	if len(s.workspaceFolders) == 0 && params.RootURI != "" {
+		s.workspaceFolders = append(s.workspaceFolders, params.RootURI)
+	}
+
+	config, err := parseConfig(params.InitializationOptions)
+	if err != nil {
+		log.Println(err)
+	}
+
+	s.config = config
+
+	return protocol.InitializeResult317{
+		Capabilities: protocol.ServerCapabilities317{
+			ServerCapabilities: protocol.ServerCapabilities{
+				TextDocumentSync: protocol.Full,
+				CompletionProvider: protocol.CompletionOptions{
+					TriggerCharacters: CompletionTriggerCharacters,
+				},
+				HoverProvider:             true,
+				ReferencesProvider:        true,
+				DocumentHighlightProvider: true,
+				DefinitionProvider:        true,
+				DocumentLinkProvider:      protocol.DocumentLinkOptions{},
+				WorkspaceSymbolProvider:   true,
+				RenameProvider:            protocol.RenameOptions{PrepareProvider: true},
+				CodeActionProvider: protocol.CodeActionOptions{
+					CodeActionKinds: []protocol.CodeActionKind{protocol.QuickFix, protocol.Source},
 				},
-				Full: true,
+				ExecuteCommandProvider: protocol.ExecuteCommandOptions{
+					Commands: Commands,
+				},
+				SemanticTokensProvider: protocol.SemanticTokensOptions{
+					Legend: protocol.SemanticTokensLegend{
+						TokenTypes: TokenTypes,
+					},
+					Full: true,
+				},
+			},
+			// Schemas and Go types are in other files, which affect the diagnostics of the documents using them.
+			DiagnosticProvider: &protocol.DiagnosticOptions{
+				InterFileDependencies: true,
+				WorkspaceDiagnostics:  true,
 			},
 		},
 	}
@@ -41,11 +102,64 @@ func (s *Server) Initialize(params *protocol.InitializeParams) protocol.Initiali
 
 // Initialized tells us, that the client is ready.
 func (s *Server) Initialized() {
+	s.scanWorkspace()
+}
+
+// The user's settings changed.
+func (s *Server) DidChangeConfiguration(params *protocol.DidChangeConfigurationParams) {
+	config, err := parseConfig(params.Settings)
+	if err != nil {
+		log.Println(err)
+
+		return
+	}
+
+	s.config = config
+	s.reindexAll()
+	s.refreshDiagnostics()
 }
 
-// Handle a hover event.
-func (t *Server) Hover(params *protocol.HoverParams) protocol.Hover {
-	return protocol.Hover{} // Don't forget to enable hover capabilities when using this.
+// Handle a hover event by previewing the target of a reference, or showing the documentation of the element or attribute from the schema.
+func (s *Server) Hover(params *protocol.HoverParams) *protocol.Hover {
+	file, ok := s.files[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	doc := ParseDocument(file)
+
+	if ref, ok := s.crossRefAt(doc, params.Position); ok {
+		return s.crossRefHover(ref)
+	}
+
+	schema := s.schemaOf(doc)
+	if schema == nil {
+		return nil
+	}
+
+	if node, attr := doc.AttributeAt(params.Position); attr != nil {
+		if element := schema.ElementFor(node); element != nil {
+			if attrSchema := element.Attribute(attr.Key); attrSchema != nil {
+				return &protocol.Hover{
+					Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: attrSchema.Describe()},
+					Range:    attr.KeyRange,
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if node := doc.NodeAt(params.Position); node != nil {
+		if element := schema.ElementFor(node); element != nil {
+			return &protocol.Hover{
+				Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: element.Describe()},
+				Range:    node.NameRange,
+			}
+		}
+	}
+
+	return nil
 }
 
 // A document was saved.
@@ -58,13 +172,33 @@ func (s *Server) DidOpenTextDocument(params *protocol.DidOpenTextDocumentParams)
 	s.files[params.TextDocument.URI] = File{
 		Uri:     params.TextDocument.URI,
 		Content: params.TextDocument.Text,
+		Version: params.TextDocument.Version,
 	}
+
+	if s.reindex(uriToPath(params.TextDocument.URI)) {
+		s.refreshDiagnostics()
+
+		return
+	}
+
 	s.sendDiagnostics()
 }
 
 // A document was close.
 func (s *Server) DidCloseTextDocument(params *protocol.DidCloseTextDocumentParams) {
 	delete(s.files, params.TextDocument.URI)
+	s.stopPreview(params.TextDocument.URI)
+
+	if s.reindex(uriToPath(params.TextDocument.URI)) {
+		s.refreshDiagnostics()
+
+		return
+	}
+
+	// Files of the workspace still have diagnostics, now for their content on disk.
+	if file, ok := s.workspaceFiles[uriToPath(params.TextDocument.URI)]; ok {
+		s.sendFileDiagnostics(file)
+	}
 }
 
 // A document was changed.
@@ -73,7 +207,17 @@ func (s *Server) DidChangeTextDocument(params *protocol.DidChangeTextDocumentPar
 	s.files[params.TextDocument.URI] = File{
 		Uri:     params.TextDocument.URI,
 		Content: params.ContentChanges[0].Text,
+		Version: params.TextDocument.Version,
 	}
+
+	s.schedulePreview(params.TextDocument.URI)
+
+	if s.reindex(uriToPath(params.TextDocument.URI)) {
+		s.refreshDiagnostics()
+
+		return
+	}
+
 	s.sendDiagnostics()
 }
 
@@ -124,63 +268,59 @@ func (s *Server) FullSemanticTokens(params *protocol.SemanticTokensParams) proto
 	}
 }
 
-func (s *Server) EncodeXML(filename protocol.DocumentURI) string {
-	var out strings.Builder
-	in := strings.NewReader(s.files[filename].Content)
-
-	enc := encoder.NewXMLEncoder(filepath.Base(string(filename)), in, &out)
-	err := enc.Encode()
-	if err != nil {
-		return ""
+// sendDiagnostics sends any parser errors, unless the client pulls them.
+func (s *Server) sendDiagnostics() {
+	if s.pullDiagnostics {
+		return
 	}
 
-	return out.String()
+	for _, file := range s.files {
+		_ = SendNotification("textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+			URI:         protocol.DocumentURI(file.Uri),
+			Diagnostics: s.diagnostics(file),
+		})
+	}
 }
 
-// sendDiagnostics sends any parser errors.
-func (s *Server) sendDiagnostics() {
-	for _, file := range s.files {
+// diagnostics returns the syntax errors and all other problems of a file.
+func (s *Server) diagnostics(file File) []protocol.Diagnostic {
+	doc := ParseDocument(file)
 
-		fileContent := file.Content
-		fileContent = strings.ToLower(fileContent)
-		fileName := filepath.Base(string(file.Uri))
+	// Problems of validation and linting come with fixes, which also help with some of the parser's errors,
+	// like duplicate attributes.
+	diagnostics := diagnosticsOf(syntaxProblems(doc))
 
-		// Parse file for any errors. Ideally we would be able to catch multiple errors and then recover.
-		// Currently only the first error will be reported.
-		diagnostics := []protocol.Diagnostic{}
-		parser := parser.NewParser(fileName, strings.NewReader(fileContent))
-		_, err := parser.Parse()
-		if err != nil {
-			switch e := err.(type) {
-			case *token.PosError:
-				for _, detail := range e.Details {
-					diagnostics = append(diagnostics, protocol.Diagnostic{
-						Range: protocol.Range{
-							Start: protocol.Position{
-								// Subtract 1 since dyml has 1 based lines and columns, but LSP wants 0 based
-								Line:      uint32(detail.Node.Begin().Line) - 1,
-								Character: uint32(detail.Node.Begin().Col) - 1,
-							},
-							End: protocol.Position{
-								Line:      uint32(detail.Node.End().Line) - 1,
-								Character: uint32(detail.Node.End().Col) - 1,
-							},
-						},
-						Severity: protocol.SeverityError,
-						Message:  e.Error(),
-					})
-				}
-			default:
-				diagnostics = append(diagnostics, protocol.Diagnostic{
-					Severity: protocol.SeverityError,
-					Message:  e.Error(),
-				})
-			}
-		}
+	return append(diagnostics, diagnosticsOf(s.problems(doc))...)
+}
 
-		_ = SendNotification("textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
-			URI:         protocol.DocumentURI(file.Uri),
-			Diagnostics: diagnostics,
-		})
+// schemaProblems validates a document against its schema, if it has one.
+// Failing to load the schema is reported as a problem, too.
+func (s *Server) schemaProblems(doc *Document) []Problem {
+	uri, where, ok, err := s.schemaFor(doc)
+	if !ok {
+		return nil
+	}
+
+	if err != nil {
+		return []Problem{{Diagnostic: protocol.Diagnostic{
+			Range:           where,
+			Severity:        protocol.SeverityError,
+			Code:            "schema-load-failed",
+			CodeDescription: codeDescription("schema-load-failed"),
+			Message:         err.Error(),
+		}}}
 	}
+
+	schema, err := s.loadSchema(uri)
+	if err != nil {
+		return []Problem{{Diagnostic: protocol.Diagnostic{
+			Range:           where,
+			Severity:        protocol.SeverityError,
+			Code:            "schema-load-failed",
+			CodeDescription: codeDescription("schema-load-failed"),
+			Message:         fmt.Sprintf("failed to load schema %s: %s", filepath.Base(uriToPath(uri)), err),
+		}}}
+	}
+
+	return Validate(doc, schema)
 }
@@ -0,0 +1,96 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+)
+
+// SourceMapping maps a part of the XML of a document to the element, attribute, text or comment it comes from.
+type SourceMapping struct {
+	// XML is the range in the XML.
+	XML protocol.Range `json:"xml"`
+	// Source is the range in the document, or in a document it includes.
+	Source protocol.Location `json:"source"`
+}
+
+// XMLToSourceParams are the parameters of custom/xmlToSource.
+type XMLToSourceParams struct {
+	// TextDocument is the document that was encoded as XML.
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	// Position is a position in the XML.
+	Position protocol.Position `json:"position"`
+}
+
+// SourceToXMLParams are the parameters of custom/sourceToXML.
+type SourceToXMLParams struct {
+	// TextDocument is the document that was encoded as XML.
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	// Position is a position in the document, or in the source document.
+	Position protocol.Position `json:"position"`
+	// Source is the document of the position, if it is a document that is included and not the encoded one.
+	Source protocol.DocumentURI `json:"source,omitempty"`
+}
+
+// rangeWithin returns true if the inner range is inside the outer range, including both ends.
+func rangeWithin(inner, outer protocol.Range) bool {
+	return rangeContains(outer, inner.Start) && rangeContains(outer, inner.End)
+}
+
+// sourceMapOf returns the source map of the XML in the preview of a document, or encodes it with the settings
+// if it has no preview. Other encodes, like those of a selection, are not kept, as their XML is not the preview's.
+func (s *Server) sourceMapOf(uri protocol.DocumentURI) []SourceMapping {
+	if sub, ok := s.previews[uri]; ok {
+		return sub.sourceMap
+	}
+
+	params := &EncodeParams{Format: FormatXML}
+	params.TextDocument.URI = uri
+
+	return s.Encode(params).SourceMap
+}
+
+// XMLToSource returns where the innermost element, attribute, text or comment at a position in the XML
+// comes from, or nil if the position is not inside any of them.
+func (s *Server) XMLToSource(params *XMLToSourceParams) *protocol.Location {
+	var found *SourceMapping
+
+	mappings := s.sourceMapOf(params.TextDocument.URI)
+	for i, mapping := range mappings {
+		if rangeContains(mapping.XML, params.Position) && (found == nil || rangeWithin(mapping.XML, found.XML)) {
+			found = &mappings[i]
+		}
+	}
+
+	if found == nil {
+		return nil
+	}
+
+	return &found.Source
+}
+
+// SourceToXML returns the range in the XML of the innermost element, attribute, text or comment at a position
+// in the document, or nil if there is none.
+func (s *Server) SourceToXML(params *SourceToXMLParams) *protocol.Range {
+	source := params.Source
+	if source == "" {
+		source = params.TextDocument.URI
+	}
+
+	var found *SourceMapping
+
+	mappings := s.sourceMapOf(params.TextDocument.URI)
+	for i, mapping := range mappings {
+		if uriToPath(mapping.Source.URI) != uriToPath(source) || !rangeContains(mapping.Source.Range, params.Position) {
+			continue
+		}
+
+		if found == nil || rangeWithin(mapping.Source.Range, found.Source.Range) {
+			found = &mappings[i]
+		}
+	}
+
+	if found == nil {
+		return nil
+	}
+
+	return &found.XML
+}
@@ -0,0 +1,33 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"reflect"
+	"testing"
+)
+
+// TestSourceMapOfPreview checks that positions are translated with the XML of the preview,
+// when the document was encoded differently after it.
+func TestSourceMapOfPreview(t *testing.T) {
+	uri := protocol.DocumentURI("file:///test.dyml")
+	s := NewServer()
+	s.files[uri] = File{Uri: uri, Content: "#a {x}\n#b {y}\n", Version: 1}
+
+	s.PreviewSubscribe(&PreviewSubscribeParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+
+	params := &SourceToXMLParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Position: protocol.Position{Line: 1, Character: 1}}
+
+	want := s.SourceToXML(params)
+	if want == nil {
+		t.Fatal("no XML for the element")
+	}
+
+	selection := &EncodeParams{Format: FormatXML}
+	selection.TextDocument.URI = uri
+	selection.Range = &protocol.Range{Start: protocol.Position{Line: 1, Character: 1}, End: protocol.Position{Line: 1, Character: 1}}
+	s.Encode(selection)
+
+	if got := s.SourceToXML(params); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after encoding a selection, want %v", got, want)
+	}
+}
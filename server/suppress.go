@@ -0,0 +1,237 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"strings"
+
+	"github.com/golangee/dyml/token"
+)
+
+// Directives in comments that suppress problems. Without rule IDs they suppress every rule.
+const (
+	// disableNextLineDirective suppresses problems on the line after the comment.
+	disableNextLineDirective = "dyml-disable-next-line"
+	// disableDirective suppresses problems until the next enableDirective or the end of the document.
+	disableDirective = "dyml-disable"
+	enableDirective  = "dyml-enable"
+)
+
+// unusedDirectiveRule reports directives that suppress nothing. It is not checked like the other
+// rules, because it needs to know about all problems.
+var unusedDirectiveRule = &LintRule{
+	ID:          "unused-directive",
+	Description: "A directive that suppresses problems does not suppress anything.",
+	Severity:    protocol.SeverityWarning,
+	Tags:        []protocol.DiagnosticTag{protocol.Unnecessary},
+}
+
+// suppression is a directive that suppresses problems of some rules in a range of lines.
+type suppression struct {
+	// where is the range of the directive's comment.
+	where protocol.Range
+	// rules are the IDs the directive names, nil for all rules.
+	rules []string
+	// firstLine and lastLine are the lines in which problems are suppressed.
+	firstLine, lastLine uint32
+	// used records which rules suppressed something, "" stands for all rules.
+	used map[string]bool
+}
+
+// suppresses returns true if the problem is suppressed and records that the suppression was used.
+func (s *suppression) suppresses(problem Problem) bool {
+	code, ok := problem.Diagnostic.Code.(string)
+	if !ok || code == "" {
+		return false
+	}
+
+	line := problem.Diagnostic.Range.Start.Line
+	if line < s.firstLine || line > s.lastLine {
+		return false
+	}
+
+	if s.rules == nil {
+		s.used[""] = true
+
+		return true
+	}
+
+	for _, rule := range s.rules {
+		if rule == code {
+			s.used[rule] = true
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// findSuppressions reads all suppressing directives from the comments in the document.
+func findSuppressions(doc *Document) []*suppression {
+	var (
+		suppressions []*suppression
+		// open are the disable directives that were not enabled again yet.
+		open []*suppression
+	)
+
+	lastLine := doc.End().Line
+
+	for i, tok := range doc.Tokens {
+		if tok.Type() != token.TokenG1Comment && tok.Type() != token.TokenG2Comment || i+1 >= len(doc.Tokens) {
+			continue
+		}
+
+		text, ok := doc.Tokens[i+1].(*token.CharData)
+		if !ok {
+			continue
+		}
+
+		// Directives are on the first line of a comment, G1 comments can span more.
+		firstLine := text.SplitLines()[0]
+		value := strings.TrimRight(firstLine.Value, " \t\r")
+
+		fields := strings.FieldsFunc(value, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(fields) == 0 {
+			continue
+		}
+
+		start := toPosition(tok.Pos().Begin())
+		where := protocol.Range{Start: start, End: toPosition(firstLine.Begin())}
+		where.End.Character += runeCount(value)
+
+		var rules []string
+		if len(fields) > 1 {
+			rules = fields[1:]
+		}
+
+		switch fields[0] {
+		case disableNextLineDirective:
+			suppressions = append(suppressions, &suppression{
+				where:     where,
+				rules:     rules,
+				firstLine: start.Line + 1,
+				lastLine:  start.Line + 1,
+				used:      make(map[string]bool),
+			})
+		case disableDirective:
+			s := &suppression{
+				where:     where,
+				rules:     rules,
+				firstLine: start.Line,
+				lastLine:  lastLine,
+				used:      make(map[string]bool),
+			}
+			suppressions = append(suppressions, s)
+			open = append(open, s)
+		case enableDirective:
+			// Enabling ends the disabled ranges of the named rules, or all of them.
+			var stillOpen []*suppression
+
+			for _, s := range open {
+				if rules == nil || sameRules(s.rules, rules) {
+					s.lastLine = start.Line
+				} else {
+					stillOpen = append(stillOpen, s)
+				}
+			}
+
+			open = stillOpen
+		}
+	}
+
+	return suppressions
+}
+
+// sameRules returns true if both lists name the same rules.
+func sameRules(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range a {
+		seen[rule] = true
+	}
+
+	for _, rule := range b {
+		if !seen[rule] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// suppress removes all problems that are suppressed by directives in the document and
+// reports directives that suppress nothing.
+func suppress(doc *Document, problems []Problem, config LintConfig) []Problem {
+	suppressions := findSuppressions(doc)
+	if len(suppressions) == 0 {
+		return problems
+	}
+
+	var kept []Problem
+
+	for _, problem := range problems {
+		suppressed := false
+
+		for _, s := range suppressions {
+			if s.suppresses(problem) {
+				suppressed = true
+			}
+		}
+
+		if !suppressed {
+			kept = append(kept, problem)
+		}
+	}
+
+	severity, ok := config.severity(unusedDirectiveRule)
+	if !ok {
+		return kept
+	}
+
+	l := linter{rule: unusedDirectiveRule, severity: severity, config: config}
+
+	for _, s := range suppressions {
+		fix := []Fix{removeFix("Remove directive", s.where)}
+
+		if s.rules == nil {
+			if !s.used[""] {
+				l.problem(s.where, fix, "directive does not suppress any problem")
+			}
+
+			continue
+		}
+
+		var unused []string
+
+		for _, rule := range s.rules {
+			if !s.used[rule] {
+				unused = append(unused, rule)
+			}
+		}
+
+		switch {
+		case len(unused) == len(s.rules):
+			l.problem(s.where, fix, "directive does not suppress any problem")
+		case len(unused) > 0:
+			l.problem(s.where, nil, "directive does not suppress any problem of %s", quoteList(unused))
+		}
+	}
+
+	return append(kept, l.problems...)
+}
+
+// quoteList formats a list of names for a message, e.g. "'a', 'b'".
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("'%s'", name)
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,213 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxWorkspaceSymbols limits the symbols we return for a search, clients filter them further anyway.
+const maxWorkspaceSymbols = 500
+
+// isIDAttribute returns true for attributes whose value identifies their element, like 'id', 'name' or 'user-id'.
+func isIDAttribute(key string) bool {
+	lower := strings.ToLower(key)
+
+	switch lower {
+	case "id", "name", "key":
+		return true
+	}
+
+	return strings.HasSuffix(lower, "-id") || strings.HasSuffix(lower, "_id") || strings.HasSuffix(key, "Id")
+}
+
+// documentSymbols returns the elements and the values of id-like attributes of a document, which can be searched
+// in the whole workspace. Top-level elements are namespaces, elements with children classes and others fields.
+func documentSymbols(doc *Document) []protocol.SymbolInformation {
+	var symbols []protocol.SymbolInformation
+
+	doc.Root.Walk(func(node *Node) bool {
+		if !node.IsElement() || node.IsRoot() {
+			return true
+		}
+
+		kind := protocol.Field
+
+		switch {
+		case node.Parent.IsRoot():
+			kind = protocol.Namespace
+		case len(node.Elements()) > 0:
+			kind = protocol.Class
+		}
+
+		symbols = append(symbols, protocol.SymbolInformation{
+			Name:          node.Name,
+			Kind:          kind,
+			Location:      protocol.Location{URI: doc.Uri, Range: node.NameRange},
+			ContainerName: node.Parent.Path(),
+		})
+
+		for _, attr := range node.Attributes {
+			if !isIDAttribute(attr.Key) || strings.TrimSpace(attr.Value) == "" {
+				continue
+			}
+
+			symbols = append(symbols, protocol.SymbolInformation{
+				Name:          attr.Value,
+				Kind:          protocol.Key,
+				Location:      protocol.Location{URI: doc.Uri, Range: valueRange(attr)},
+				ContainerName: node.Path() + "@" + attr.Key,
+			})
+		}
+
+		return true
+	})
+
+	return symbols
+}
+
+// valueRange returns the range of an attribute's value without the quotes of G2.
+func valueRange(attr *Attribute) protocol.Range {
+	where := attr.ValueRange
+	if where.Start.Line == where.End.Line && where.End.Character-where.Start.Character == runeCount(attr.Value)+2 {
+		where.Start.Character++
+		where.End.Character--
+	}
+
+	return where
+}
+
+// reindex updates the symbols and identifiers of the file at the path, after it was opened, changed, closed or deleted.
+// Open files are preferred over their content on disk. It returns true if the file now defines other identifiers,
+// which changes the diagnostics of other files.
+func (s *Server) reindex(path string) bool {
+	old := s.crossRefs[path]
+
+	var doc *Document
+
+	for uri, file := range s.files {
+		if uriToPath(uri) == path {
+			doc = ParseDocument(file)
+
+			break
+		}
+	}
+
+	if file, ok := s.workspaceFiles[path]; ok && doc == nil {
+		doc = ParseDocument(file)
+	}
+
+	if doc == nil {
+		delete(s.symbols, path)
+		delete(s.crossRefs, path)
+
+		return !sameDefinitions(old, nil)
+	}
+
+	s.symbols[path] = documentSymbols(doc)
+	s.crossRefs[path] = s.crossRefsOf(doc)
+
+	return !sameDefinitions(old, s.crossRefs[path])
+}
+
+// reindexAll updates the index of all files, after settings or schemas changed.
+func (s *Server) reindexAll() {
+	for _, file := range s.allFiles() {
+		s.reindex(uriToPath(file.Uri))
+	}
+}
+
+// WorkspaceSymbol searches the symbols of all files in the workspace. The characters of the query
+// have to appear in a symbol's name in the same order, but not next to each other.
+func (s *Server) WorkspaceSymbol(params *protocol.WorkspaceSymbolParams) []protocol.SymbolInformation {
+	type match struct {
+		symbol protocol.SymbolInformation
+		score  int
+	}
+
+	var matches []match
+
+	for _, symbols := range s.symbols {
+		for _, symbol := range symbols {
+			if score, ok := fuzzyScore(params.Query, symbol.Name); ok {
+				matches = append(matches, match{symbol, score})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+
+		switch {
+		case a.score != b.score:
+			return a.score > b.score
+		case a.symbol.Name != b.symbol.Name:
+			return a.symbol.Name < b.symbol.Name
+		case a.symbol.Location.URI != b.symbol.Location.URI:
+			return a.symbol.Location.URI < b.symbol.Location.URI
+		default:
+			return positionBefore(a.symbol.Location.Range.Start, b.symbol.Location.Range.Start)
+		}
+	})
+
+	if len(matches) > maxWorkspaceSymbols {
+		matches = matches[:maxWorkspaceSymbols]
+	}
+
+	symbols := []protocol.SymbolInformation{}
+	for _, m := range matches {
+		symbols = append(symbols, m.symbol)
+	}
+
+	return symbols
+}
+
+// fuzzyScore rates how well a query matches a name, ignoring case, and returns false if it does not match at all.
+// Exact matches and prefixes rate best, then characters at the start of words and characters next to each other.
+func fuzzyScore(query, name string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(name)
+
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	score := 0
+	if strings.EqualFold(query, name) {
+		score += 1000
+	}
+
+	if strings.HasPrefix(strings.ToLower(name), string(q)) {
+		score += 100
+	}
+
+	qi := 0
+	last := -2
+
+	for i := 0; i < len(n) && qi < len(q); i++ {
+		if unicode.ToLower(n[i]) != q[qi] {
+			continue
+		}
+
+		score++
+
+		if i == last+1 {
+			score += 3
+		}
+
+		if i == 0 || !unicode.IsLetter(n[i-1]) && !unicode.IsDigit(n[i-1]) || unicode.IsUpper(n[i]) && unicode.IsLower(n[i-1]) {
+			score += 5
+		}
+
+		last = i
+		qi++
+	}
+
+	if qi < len(q) {
+		return 0, false
+	}
+
+	// Shorter names are closer to what was searched for.
+	return score*100 - len(n), true
+}
@@ -0,0 +1,311 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golangee/dyml/parser"
+	"github.com/golangee/dyml/token"
+)
+
+// closingBrackets maps the types of brackets to the characters that close them.
+var closingBrackets = map[token.Type]string{
+	token.TokenBlockStart:   "}",
+	token.TokenGroupStart:   ")",
+	token.TokenGenericStart: ">",
+	token.TokenBlockEnd:     "}",
+	token.TokenGroupEnd:     ")",
+	token.TokenGenericEnd:   ">",
+}
+
+// syntaxProblems reports the syntax errors in a document, with fixes for the common mistakes.
+func syntaxProblems(doc *Document) []Problem {
+	// Parse file for any errors. Ideally we would be able to catch multiple errors and then recover.
+	// Currently only the first error will be reported.
+	fileName := filepath.Base(string(doc.Uri))
+	_, err := parser.NewParser(fileName, strings.NewReader(doc.Content)).Parse()
+
+	if err == nil {
+		err = strayBracket(doc.Tokens)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var problems []Problem
+
+	switch e := err.(type) {
+	case *token.PosError:
+		for _, detail := range e.Details {
+			where := toRange(detail.Node)
+			problems = append(problems, Problem{
+				Diagnostic: protocol.Diagnostic{
+					Range:           where,
+					Severity:        protocol.SeverityError,
+					Code:            parseErrorCode(detail.Message),
+					CodeDescription: codeDescription(parseErrorCode(detail.Message)),
+					Message:         e.Error(),
+				},
+				Fixes: syntaxFixes(doc, detail.Message, where),
+			})
+		}
+	default:
+		problems = append(problems, Problem{Diagnostic: protocol.Diagnostic{
+			Severity:        protocol.SeverityError,
+			Code:            parseErrorCode(e.Error()),
+			CodeDescription: codeDescription(parseErrorCode(e.Error())),
+			Message:         e.Error(),
+		}})
+	}
+
+	return problems
+}
+
+// strayBracket finds a closing bracket without an opening one. The parser does not report these,
+// but silently ignores everything after them.
+func strayBracket(tokens []token.Token) error {
+	depth := 0
+
+	for _, tok := range tokens {
+		switch {
+		case isOpeningBracket(tok):
+			depth++
+		case isClosingBracket(tok) && depth > 0:
+			depth--
+		case isClosingBracket(tok):
+			return token.NewPosError(tok.Pos(), fmt.Sprintf("unexpected '%s' without an opening bracket", closingBrackets[tok.Type()]))
+		}
+	}
+
+	return nil
+}
+
+// syntaxFixes returns fixes for an error of the lexer or parser at the given range.
+// They are recognized by their messages, like the codes in parseErrorCode.
+func syntaxFixes(doc *Document, message string, where protocol.Range) []Fix {
+	switch {
+	case strings.Contains(message, "unable to read next rune"):
+		return closeBracketsFix(doc)
+	case strings.Contains(message, "without an opening bracket"):
+		return []Fix{removeFix("Remove bracket", where)}
+	case strings.Contains(message, `expected '"'`) && where.Start == doc.End():
+		return closeStringFix(doc)
+	case strings.Contains(message, `expected '"'`):
+		return quoteValueFix(doc, where.Start)
+	case strings.Contains(message, "expected '{'"):
+		return braceValueFix(doc, where.Start)
+	case strings.Contains(message, "attribute value must be enclosed in '{}'"):
+		return emptyValueFix(doc, where.Start, "{}")
+	case strings.Contains(message, "'=' is expected here"):
+		if fix, ok := assignValueFix(doc, where); ok {
+			return []Fix{fix}
+		}
+
+		return emptyValueFix(doc, where.Start, `=""`)
+	default:
+		return nil
+	}
+}
+
+// closeBracketsFix closes all brackets that are still open at the end of the document.
+func closeBracketsFix(doc *Document) []Fix {
+	var open []string
+
+	for _, tok := range doc.Tokens {
+		switch {
+		case isOpeningBracket(tok):
+			open = append(open, closingBrackets[tok.Type()])
+		case isClosingBracket(tok) && len(open) > 0:
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(open) == 0 {
+		return nil
+	}
+
+	var closing strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		closing.WriteString(open[i])
+	}
+
+	return []Fix{insertFix(fmt.Sprintf("Insert missing '%s'", closing.String()), doc.End(), closing.String())}
+}
+
+// closeStringFix closes a string that runs until the end of the document at the end of the line it starts in.
+func closeStringFix(doc *Document) []Fix {
+	offset := len(doc.Content)
+
+	for {
+		offset = strings.LastIndex(doc.Content[:offset], `"`)
+		if offset < 0 {
+			return nil
+		}
+
+		// An escaped quote is preceded by an odd number of backslashes.
+		before := doc.Content[:offset]
+		if (len(before)-len(strings.TrimRight(before, `\`)))%2 == 0 {
+			break
+		}
+	}
+
+	line := strings.Count(doc.Content[:offset], "\n")
+	text := strings.TrimRight(doc.lines[line], " \t\r")
+	where := protocol.Position{Line: uint32(line), Character: runeCount(text)}
+
+	return []Fix{insertFix(`Insert missing '"'`, where, `"`)}
+}
+
+// quoteValueFix encloses a G2 attribute value, that was written without quotes, in quotes.
+func quoteValueFix(doc *Document, pos protocol.Position) []Fix {
+	assign := lastTokenBefore(doc, pos, token.TokenAssign)
+	if assign == nil {
+		return nil
+	}
+
+	value, ok := wordAfter(doc, toPosition(assign.Pos().End()))
+	if !ok {
+		return nil
+	}
+
+	return []Fix{{
+		Title: "Enclose value in quotes",
+		Edits: []protocol.TextEdit{{Range: value, NewText: `"` + doc.textIn(value) + `"`}},
+	}}
+}
+
+// braceValueFix encloses a G1 attribute value, that was written without brackets, in brackets.
+func braceValueFix(doc *Document, pos protocol.Position) []Fix {
+	key := attributeKeyBefore(doc, pos)
+	if key == nil {
+		return nil
+	}
+
+	keyEnd := toPosition(key.Pos().End())
+
+	value, ok := wordAfter(doc, keyEnd)
+	if !ok {
+		return nil
+	}
+
+	return []Fix{{
+		Title: "Enclose value in '{}'",
+		Edits: []protocol.TextEdit{{
+			Range:   protocol.Range{Start: keyEnd, End: value.End},
+			NewText: "{" + doc.textIn(value) + "}",
+		}},
+	}}
+}
+
+// assignValueFix turns the identifier after a G2 attribute key into its value, e.g. '@key value' into '@key="value"'.
+func assignValueFix(doc *Document, where protocol.Range) (Fix, bool) {
+	key := attributeKeyBefore(doc, where.Start)
+	if key == nil {
+		return Fix{}, false
+	}
+
+	// The parser reports the token it found instead of the '=', which has to be a single word.
+	tok := lastTokenBefore(doc, where.End, token.TokenIdentifier)
+	if tok == nil || toRange(tok.Pos()) != where {
+		return Fix{}, false
+	}
+
+	value := doc.textIn(where)
+
+	return Fix{
+		Title: fmt.Sprintf("Assign '%s' to '%s'", value, key.Value),
+		Edits: []protocol.TextEdit{{
+			Range:   protocol.Range{Start: toPosition(key.Pos().End()), End: where.End},
+			NewText: `="` + value + `"`,
+		}},
+	}, true
+}
+
+// emptyValueFix adds an empty value to the attribute whose key is before the position.
+func emptyValueFix(doc *Document, pos protocol.Position, value string) []Fix {
+	key := attributeKeyBefore(doc, pos)
+	if key == nil {
+		return nil
+	}
+
+	return []Fix{insertFix("Add empty value", toPosition(key.Pos().End()), value)}
+}
+
+// attributeKeyBefore returns the key of the last attribute that ends before the position.
+func attributeKeyBefore(doc *Document, pos protocol.Position) *token.Identifier {
+	var key *token.Identifier
+
+	for i, tok := range doc.Tokens {
+		if positionBefore(pos, toPosition(tok.Pos().End())) {
+			break
+		}
+
+		if id, ok := tok.(*token.Identifier); ok && i > 0 && doc.Tokens[i-1].Type() == token.TokenDefineAttribute {
+			key = id
+		}
+	}
+
+	return key
+}
+
+// lastTokenBefore returns the last token of a type that ends before the position.
+func lastTokenBefore(doc *Document, pos protocol.Position, tokenType token.Type) token.Token {
+	var found token.Token
+
+	for _, tok := range doc.Tokens {
+		if positionBefore(pos, toPosition(tok.Pos().End())) {
+			break
+		}
+
+		if tok.Type() == tokenType {
+			found = tok
+		}
+	}
+
+	return found
+}
+
+// wordAfter returns the range of the word following the position on the same line, skipping spaces.
+// A word ends with whitespace or a character with a meaning in DYML.
+func wordAfter(doc *Document, pos protocol.Position) (protocol.Range, bool) {
+	if int(pos.Line) >= len(doc.lines) {
+		return protocol.Range{}, false
+	}
+
+	line := []rune(strings.TrimSuffix(doc.lines[pos.Line], "\r"))
+
+	start := int(pos.Character)
+	for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+		start++
+	}
+
+	end := start
+	for end < len(line) && !strings.ContainsRune(" \t{}()<>,;\"#@", line[end]) {
+		end++
+	}
+
+	if end == start {
+		return protocol.Range{}, false
+	}
+
+	return protocol.Range{
+		Start: protocol.Position{Line: pos.Line, Character: uint32(start)},
+		End:   protocol.Position{Line: pos.Line, Character: uint32(end)},
+	}, true
+}
+
+// insertFix creates a fix that inserts text at a position.
+func insertFix(title string, where protocol.Position, text string) Fix {
+	return Fix{
+		Title: title,
+		Edits: []protocol.TextEdit{{Range: protocol.Range{Start: where, End: where}, NewText: text}},
+	}
+}
+
+// textIn returns the content of the document in the range.
+func (d *Document) textIn(where protocol.Range) string {
+	return d.Content[d.Offset(where.Start):d.Offset(where.End)]
+}
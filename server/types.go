@@ -23,6 +23,8 @@ const (
 type File struct {
 	Uri     protocol.DocumentURI
 	Content string
+	// Version is increased by the client with every change of an open document.
+	Version int32
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/specification-current/#textDocument_semanticTokens
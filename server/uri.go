@@ -0,0 +1,104 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// uriToPath converts a file URI to a path in the file system.
+func uriToPath(uri protocol.DocumentURI) string {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		// Some clients send URIs that are not properly escaped.
+		return filepath.FromSlash(strings.TrimPrefix(string(uri), "file://"))
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI converts a path in the file system to a file URI.
+func pathToURI(p string) protocol.DocumentURI {
+	u := url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(p),
+	}
+
+	return protocol.DocumentURI(u.String())
+}
+
+// resolveURI resolves a path relative to the directory of the document at base.
+// Absolute paths and file URIs are returned as URIs unchanged.
+func resolveURI(base protocol.DocumentURI, ref string) protocol.DocumentURI {
+	if strings.HasPrefix(ref, "file://") {
+		return protocol.DocumentURI(ref)
+	}
+
+	if filepath.IsAbs(ref) {
+		return pathToURI(ref)
+	}
+
+	return pathToURI(filepath.Join(filepath.Dir(uriToPath(base)), filepath.FromSlash(ref)))
+}
+
+// readFile returns the content of a document. Open documents are preferred over the
+// file system, so that unsaved changes are respected.
+func (s *Server) readFile(uri protocol.DocumentURI) (string, error) {
+	for openURI, file := range s.files {
+		if uriToPath(openURI) == uriToPath(uri) {
+			return file.Content, nil
+		}
+	}
+
+	buf, err := os.ReadFile(uriToPath(uri))
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// matchGlob returns true if the slash separated name matches the pattern.
+// '*' matches anything but '/', '**' matches anything and '?' matches a single character.
+// Patterns without a '/' are matched against the last element of name only.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+
+	var expr strings.Builder
+
+	expr.WriteString("^")
+
+	// The pattern is read by runes, so that characters of more than one byte are quoted as a whole.
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		rest := string(runes[i:])
+
+		switch {
+		case strings.HasPrefix(rest, "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(rest, "**"):
+			expr.WriteString(".*")
+			i++
+		case runes[i] == '*':
+			expr.WriteString("[^/]*")
+		case runes[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+
+	expr.WriteString("$")
+
+	matched, err := regexp.MatchString(expr.String(), name)
+
+	return err == nil && matched
+}
@@ -0,0 +1,29 @@
+package dyml
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.dyml", "dir/config.dyml", true},
+		{"*.dyml", "dir/config.xml", false},
+		{"dir/*.dyml", "dir/sub/config.dyml", false},
+		{"dir/**/*.dyml", "dir/sub/config.dyml", true},
+		{"**/*.dyml", "config.dyml", true},
+		{"config.?yml", "config.dyml", true},
+		{"größe.dyml", "docs/größe.dyml", true},
+		{"dokumente/**/*.dyml", "dokumente/größe.dyml", true},
+		{"gr??e.dyml", "größe.dyml", true},
+		{"日本/*.dyml", "日本/設定.dyml", true},
+		{"日本/*.dyml", "中国/設定.dyml", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"strings"
+)
+
+// Validate checks a document against a schema and returns all violations.
+func Validate(doc *Document, schema *Schema) []Problem {
+	v := validator{}
+	v.element(doc.Root, schema.Root)
+
+	return v.problems
+}
+
+// validator collects problems while walking a document and its schema side by side.
+type validator struct {
+	problems []Problem
+}
+
+// report adds a problem with a diagnostic code, which links to the code's documentation.
+func (v *validator) report(code string, severity protocol.DiagnosticSeverity, where protocol.Range, fixes []Fix, format string, args ...interface{}) *protocol.Diagnostic {
+	v.problems = append(v.problems, Problem{
+		Diagnostic: protocol.Diagnostic{
+			Range:           where,
+			Severity:        severity,
+			Code:            code,
+			CodeDescription: codeDescription(code),
+			Message:         fmt.Sprintf(format, args...),
+		},
+		Fixes: fixes,
+	})
+
+	return &v.problems[len(v.problems)-1].Diagnostic
+}
+
+// element validates the node against its schema and continues with all children.
+func (v *validator) element(node *Node, schema *ElementSchema) {
+	for _, attr := range node.Attributes {
+		attrSchema := schema.Attribute(attr.Key)
+		if attrSchema == nil {
+			if !schema.Open {
+				v.report("schema-unknown-attribute", protocol.SeverityError, attr.KeyRange, []Fix{removeFix(fmt.Sprintf("Remove attribute '%s'", attr.Key), attr.Range)},
+					"attribute '%s' is not allowed on %s", attr.Key, describe(node))
+			}
+
+			continue
+		}
+
+		if problem := attrSchema.Check(attr.Value); problem != "" {
+			var fixes []Fix
+			for _, value := range attrSchema.Values() {
+				fixes = append(fixes, Fix{
+					Title: fmt.Sprintf("Change to '%s'", value),
+					Edits: []protocol.TextEdit{{Range: attr.ValueRange, NewText: formatValue(node, value)}},
+				})
+			}
+
+			v.report("schema-invalid-value", protocol.SeverityError, attr.ValueRange, fixes, "%s", problem)
+		}
+
+		if attrSchema.Deprecated != "" {
+			d := v.report("schema-deprecated", protocol.SeverityWarning, attr.KeyRange, nil, "%s", deprecationMessage("attribute '"+attr.Key+"'", attrSchema.Deprecated))
+			d.Tags = []protocol.DiagnosticTag{protocol.Deprecated}
+		}
+	}
+
+	for _, attrSchema := range schema.Attributes {
+		if attrSchema.Required && node.Attribute(attrSchema.Name) == nil {
+			v.report("schema-missing-attribute", protocol.SeverityError, node.NameRange, []Fix{addAttributeFix(node, attrSchema)},
+				"%s is missing the required attribute '%s'", describe(node), attrSchema.Name)
+		}
+	}
+
+	hasText := false
+	counts := make(map[string]int)
+
+	for _, child := range node.Children {
+		switch {
+		case child.Text != nil:
+			if strings.TrimSpace(*child.Text) == "" {
+				continue
+			}
+
+			hasText = true
+
+			if schema.Text == TextForbidden {
+				v.report("schema-text-forbidden", protocol.SeverityError, child.Range, []Fix{removeFix("Remove text", child.Range)},
+					"%s must not contain text", describe(node))
+			}
+		case child.IsElement():
+			childSchema := schema.Child(child.Name)
+			if childSchema == nil {
+				if !schema.Open {
+					v.report("schema-unknown-element", protocol.SeverityError, child.NameRange, []Fix{removeFix(fmt.Sprintf("Remove element '%s'", child.Name), child.Range)},
+						"element '%s' is not allowed in %s", child.Name, describe(node))
+				}
+
+				continue
+			}
+
+			counts[child.Name]++
+			if childSchema.Max >= 0 && counts[child.Name] == childSchema.Max+1 {
+				v.report("schema-too-many", protocol.SeverityError, child.NameRange, []Fix{removeFix(fmt.Sprintf("Remove element '%s'", child.Name), child.Range)},
+					"%s must not contain more than %d '%s'", describe(node), childSchema.Max, child.Name)
+			}
+
+			if childSchema.Deprecated != "" {
+				d := v.report("schema-deprecated", protocol.SeverityWarning, child.NameRange, nil, "%s", deprecationMessage("element '"+child.Name+"'", childSchema.Deprecated))
+				d.Tags = []protocol.DiagnosticTag{protocol.Deprecated}
+			}
+
+			v.element(child, childSchema)
+		}
+	}
+
+	if schema.Text == TextRequired && !hasText {
+		v.report("schema-text-required", protocol.SeverityError, node.NameRange, nil, "%s requires text", describe(node))
+	}
+
+	for _, childSchema := range schema.Children {
+		if counts[childSchema.Name] < childSchema.Min {
+			v.report("schema-too-few", protocol.SeverityError, node.NameRange, nil,
+				"%s must contain at least %d '%s'", describe(node), childSchema.Min, childSchema.Name)
+		}
+	}
+}
+
+// describe names an element in a message.
+func describe(node *Node) string {
+	if node.IsRoot() {
+		return "the document"
+	}
+
+	return "'" + node.Name + "'"
+}
+
+// removeFix creates a fix that deletes everything in the range.
+func removeFix(title string, where protocol.Range) Fix {
+	return Fix{
+		Title: title,
+		Edits: []protocol.TextEdit{{Range: where, NewText: ""}},
+	}
+}
+
+// addAttributeFix creates a fix that adds the attribute to the element, after all its other attributes.
+func addAttributeFix(node *Node, attrSchema *AttributeSchema) Fix {
+	where := node.NameRange.End
+	for _, attr := range node.Attributes {
+		if !attr.Forwarded {
+			where = maxPosition(where, attr.Range.End)
+		}
+	}
+
+	value := ""
+	if values := attrSchema.Values(); len(values) > 0 {
+		value = values[0]
+	}
+
+	return Fix{
+		Title: fmt.Sprintf("Add attribute '%s'", attrSchema.Name),
+		Edits: []protocol.TextEdit{{
+			Range:   protocol.Range{Start: where, End: where},
+			NewText: " " + formatAttribute(node, attrSchema.Name, value),
+		}},
+	}
+}
+
+// formatValue formats an attribute value to replace an attribute's ValueRange.
+// This includes the quotes in G2, while G1 values are surrounded by brackets outside of the range.
+func formatValue(node *Node, value string) string {
+	if node.G2 {
+		return `"` + g2Escaper.Replace(value) + `"`
+	}
+
+	return g1ValueEscaper.Replace(value)
+}
+
+// formatAttribute formats an attribute for the grammar of the element.
+func formatAttribute(node *Node, key, value string) string {
+	if node.G2 {
+		return "@" + key + "=" + formatValue(node, value)
+	}
+
+	return "@" + key + "{" + formatValue(node, value) + "}"
+}
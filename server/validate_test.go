@@ -0,0 +1,38 @@
+package dyml
+
+import "testing"
+
+// TestFormatAttribute checks that formatted attributes parse to their value again.
+func TestFormatAttribute(t *testing.T) {
+	values := []string{`plain`, `a\b`, `ends with \`, `}`, `\}`, `"quoted"`, `\"`, `#`}
+
+	for _, value := range values {
+		for _, g2 := range []bool{false, true} {
+			content := "#a " + formatAttribute(&Node{}, "key", value) + " {x}\n"
+			if g2 {
+				content = "#! a " + formatAttribute(&Node{G2: true}, "key", value) + " {}\n"
+			}
+
+			doc := ParseDocument(File{Uri: "file:///test.dyml", Content: content})
+			if doc.Err != nil {
+				t.Errorf("%q: %v", content, doc.Err)
+
+				continue
+			}
+
+			var got *Attribute
+
+			doc.Root.Walk(func(node *Node) bool {
+				if len(node.Attributes) > 0 {
+					got = node.Attributes[0]
+				}
+
+				return got == nil
+			})
+
+			if got == nil || got.Value != value {
+				t.Errorf("%q: got %v, want %q", content, got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// scanProgressToken identifies the progress of scanning the workspace.
+const scanProgressToken = "dyml-workspace-scan"
+
+// Events returns functions that background work wants to run on the server. The server is not safe
+// for concurrent use, so they must be called in between requests, from the same goroutine.
+func (s *Server) Events() <-chan func() {
+	return s.events
+}
+
+// scanWorkspace reads all DYML files in the workspace folders in the background,
+// so that we can publish their diagnostics without the user opening them.
+func (s *Server) scanWorkspace() {
+	folders := append([]protocol.DocumentURI(nil), s.workspaceFolders...)
+	if len(folders) == 0 {
+		return
+	}
+
+	if s.capabilities.Window.WorkDoneProgress {
+		_ = SendRequest("window/workDoneProgress/create", protocol.WorkDoneProgressCreateParams{Token: scanProgressToken})
+	}
+
+	s.sendProgress(protocol.WorkDoneProgressBegin{Kind: "begin", Title: "Scanning DYML files"})
+
+	events := s.events
+
+	go func() {
+		var paths []string
+		for _, folder := range folders {
+			paths = append(paths, findDymlFiles(uriToPath(folder))...)
+		}
+
+		for i, path := range paths {
+			path := path
+
+			buf, err := os.ReadFile(path)
+			if err != nil {
+				log.Println(err)
+
+				continue
+			}
+
+			file := File{Uri: pathToURI(path), Content: string(buf)}
+			percentage := uint32((i + 1) * 100 / len(paths))
+
+			events <- func() {
+				s.workspaceFiles[path] = file
+				s.reindex(path)
+				s.sendFileDiagnostics(file)
+				s.sendProgress(protocol.WorkDoneProgressReport{
+					Kind:       "report",
+					Message:    filepath.Base(path),
+					Percentage: percentage,
+				})
+			}
+		}
+
+		events <- func() {
+			s.sendProgress(protocol.WorkDoneProgressEnd{Kind: "end", Message: fmt.Sprintf("%d files", len(paths))})
+
+			// Identifiers may be referenced in files that were scanned before the one defining them.
+			if s.pullDiagnostics || s.hasCrossRefs() {
+				s.refreshDiagnostics()
+			}
+		}
+	}()
+}
+
+// sendProgress reports how far scanning the workspace is, if the client shows progress created by the server.
+func (s *Server) sendProgress(value interface{}) {
+	if !s.capabilities.Window.WorkDoneProgress {
+		return
+	}
+
+	_ = SendNotification("$/progress", protocol.ProgressParams{Token: scanProgressToken, Value: value})
+}
+
+// findDymlFiles returns the paths of all DYML files in a folder and its subfolders.
+// Hidden folders and dependencies are skipped.
+func findDymlFiles(root string) []string {
+	var paths []string
+
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if entry.IsDir() {
+			if path != root && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) == ".dyml" {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	return paths
+}
+
+// DidChangeWatchedFiles keeps the files of the workspace in sync with the file system.
+func (s *Server) DidChangeWatchedFiles(params *protocol.DidChangeWatchedFilesParams) {
+	// Schemas, Go types, identifiers and includes can change the diagnostics of every document.
+	everything, changed := false, false
+
+	for _, change := range params.Changes {
+		path := uriToPath(change.URI)
+
+		switch filepath.Ext(path) {
+		case ".go":
+			everything, changed = true, true
+
+			continue
+		case ".dyml":
+			changed = true
+		default:
+			continue
+		}
+
+		if old, ok := s.workspaceFiles[path]; ok && IsSchema(ParseDocument(old)) {
+			everything = true
+		}
+
+		// Includes of this file in other documents might be found now, or not anymore.
+		if change.Type != protocol.Changed {
+			everything = true
+		}
+
+		if change.Type == protocol.Deleted {
+			delete(s.workspaceFiles, path)
+
+			if s.reindex(path) {
+				everything = true
+			}
+
+			if !s.isOpen(path) && !s.pullDiagnostics {
+				_ = SendNotification("textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+					URI:         change.URI,
+					Diagnostics: []protocol.Diagnostic{},
+				})
+			}
+
+			continue
+		}
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			log.Println(err)
+
+			continue
+		}
+
+		file := File{Uri: change.URI, Content: string(buf)}
+		s.workspaceFiles[path] = file
+
+		if s.reindex(path) || IsSchema(ParseDocument(file)) {
+			everything = true
+		}
+
+		if !everything {
+			s.sendFileDiagnostics(file)
+		}
+	}
+
+	if everything {
+		s.reindexAll()
+	}
+
+	// Pulled diagnostics of the changed files, and of documents including or referring to them, are outdated.
+	if s.pullDiagnostics {
+		if changed {
+			s.refreshDiagnostics()
+		}
+
+		return
+	}
+
+	if everything {
+		s.sendWorkspaceDiagnostics()
+	}
+
+	s.sendDiagnostics()
+}
+
+// refreshDiagnostics updates the diagnostics of all files, after something changed that affects more than one of them.
+func (s *Server) refreshDiagnostics() {
+	if s.pullDiagnostics {
+		// The client has to pull again, we cannot tell it which documents changed. Clients that cannot
+		// be told to do so pull when documents are edited or saved.
+		if s.capabilities.Workspace.Diagnostics.RefreshSupport {
+			_ = SendRequest("workspace/diagnostic/refresh", nil)
+		}
+
+		return
+	}
+
+	s.sendDiagnostics()
+	s.sendWorkspaceDiagnostics()
+}
+
+// sendWorkspaceDiagnostics sends the diagnostics of all files in the workspace, that are not open.
+// Those of open files are sent by sendDiagnostics.
+func (s *Server) sendWorkspaceDiagnostics() {
+	for _, file := range s.allFiles() {
+		s.sendFileDiagnostics(file)
+	}
+}
+
+// sendFileDiagnostics sends the diagnostics of a file from the workspace, unless it is open or the client pulls them.
+func (s *Server) sendFileDiagnostics(file File) {
+	if s.pullDiagnostics || s.isOpen(uriToPath(file.Uri)) {
+		return
+	}
+
+	_ = SendNotification("textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+		URI:         file.Uri,
+		Diagnostics: s.diagnostics(file),
+	})
+}
+
+// isOpen returns true if the file at the path is open in the editor.
+func (s *Server) isOpen(path string) bool {
+	for uri := range s.files {
+		if uriToPath(uri) == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allFiles returns all open files and all files of the workspace, sorted by their URI.
+// Open files are preferred over the content on disk, so that unsaved changes are respected.
+func (s *Server) allFiles() []File {
+	files := make([]File, 0, len(s.files)+len(s.workspaceFiles))
+	open := make(map[string]bool)
+
+	for _, file := range s.files {
+		files = append(files, file)
+		open[uriToPath(file.Uri)] = true
+	}
+
+	for path, file := range s.workspaceFiles {
+		if !open[path] {
+			files = append(files, file)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Uri < files[j].Uri
+	})
+
+	return files
+}
@@ -0,0 +1,334 @@
+package dyml
+
+import (
+	"dyml-support/protocol"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// XMLOptions control how a document is written as XML. The zero value writes XML like the encoder of dyml.
+type XMLOptions struct {
+	// ExpandIncludes replaces includes with the content of the included documents.
+	ExpandIncludes bool `json:"expandIncludes"`
+	// RootName is the name of the root element, which is "root" if it is empty.
+	RootName string `json:"rootName"`
+	// Indent is written for each level of nesting, four spaces if it is empty.
+	Indent string `json:"indent"`
+	// Minify writes everything in a single line without indentation.
+	Minify bool `json:"minify"`
+	// Declaration starts the XML with a declaration like <?xml version="1.0" encoding="UTF-8"?>.
+	Declaration bool `json:"declaration"`
+	// Encoding is named in the declaration, "UTF-8" if it is empty. The XML is not converted to it.
+	Encoding string `json:"encoding"`
+	// Namespaces maps prefixes to namespace URIs, which are declared on the root element. Names of elements
+	// and attributes, that start with a prefix and a dot like "svg.rect", get the prefix "svg:rect" in XML.
+	// The empty prefix declares the default namespace.
+	Namespaces map[string]string `json:"namespaces"`
+	// OmitComments leaves out all comments.
+	OmitComments bool `json:"omitComments"`
+}
+
+// xmlEscaper replaces the characters that are reserved in XML.
+var xmlEscaper = strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", `"`, "&quot;")
+
+// expander expands includes while the tree of a document is written. Documents that are already
+// being written are not expanded again, which would never end.
+type expander struct {
+	expand bool
+	// resolve returns the include of an element in a document and the included document.
+	resolve func(doc *Document, node *Node) (include, *Document, bool)
+	// docs are the documents being written, the last one is the current. The first one includes the next and so on.
+	docs []*Document
+}
+
+// current returns the document that is being written.
+func (e *expander) current() *Document {
+	return e.docs[len(e.docs)-1]
+}
+
+// included returns the include of an element and the included document, if it is expanded.
+func (e *expander) included(node *Node) (include, *Document, bool) {
+	if !e.expand || e.resolve == nil {
+		return include{}, nil, false
+	}
+
+	inc, doc, ok := e.resolve(e.current(), node)
+	if !ok {
+		return include{}, nil, false
+	}
+
+	for _, writing := range e.docs {
+		if uriToPath(writing.Uri) == uriToPath(doc.Uri) {
+			return include{}, nil, false
+		}
+	}
+
+	return inc, doc, true
+}
+
+// within calls fn while the included document is the current one.
+func (e *expander) within(doc *Document, fn func()) {
+	e.docs = append(e.docs, doc)
+	fn()
+	e.docs = e.docs[:len(e.docs)-1]
+}
+
+// xmlWriter writes the tree of a document as XML, in the same format as the encoder of dyml.
+// Unlike that encoder it works on our Nodes, so that documents can be combined before they are written.
+type xmlWriter struct {
+	expander
+	sb      strings.Builder
+	options XMLOptions
+	// pos is the end of the XML written so far.
+	pos protocol.Position
+	// mappings map the XML of each element, attribute, text and comment to its source.
+	mappings []SourceMapping
+}
+
+// write writes XML and keeps track of the position.
+func (w *xmlWriter) write(xml string) {
+	w.sb.WriteString(xml)
+	w.pos = advance(w.pos, xml)
+}
+
+// mapped maps the XML from start to the current position to the source in the current document.
+func (w *xmlWriter) mapped(start protocol.Position, source protocol.Range) {
+	w.mappings = append(w.mappings, SourceMapping{
+		XML:    protocol.Range{Start: start, End: w.pos},
+		Source: protocol.Location{URI: w.current().Uri, Range: source},
+	})
+}
+
+// content writes the top level of an included document.
+func (w *xmlWriter) content(doc *Document, depth int) {
+	w.within(doc, func() {
+		for _, child := range doc.Root.Children {
+			w.node(child, depth)
+		}
+	})
+}
+
+// element writes an element with its attributes and all of its children.
+func (w *xmlWriter) element(node *Node, depth int) {
+	inc, included, expand := w.included(node)
+
+	// An include element is replaced by the content of the included document,
+	// other elements keep their name and get the content as additional children.
+	if expand && inc.replace {
+		w.content(included, depth)
+
+		return
+	}
+
+	// A named return arrow, like "-> name", only stands for the named element.
+	if isNamedReturn(w.current(), node) {
+		w.element(node.Children[0], depth)
+
+		return
+	}
+
+	name := w.name(node.Name)
+
+	w.write(w.indent(depth))
+	start := w.pos
+
+	// Forwarded elements are written in a single line, if there is nothing inside.
+	if node.Forwarded && len(node.Attributes) == 0 && len(node.Children) == 0 {
+		w.write("<" + name + "></" + name + ">")
+		w.mapped(start, node.Range)
+		w.write(w.newline())
+
+		return
+	}
+
+	w.write("<" + name)
+
+	for _, attr := range node.Attributes {
+		if expand && attr == inc.attr {
+			continue
+		}
+
+		w.write(" ")
+		attrStart := w.pos
+		w.write(w.name(attr.Key) + `="` + xmlEscaper.Replace(attr.Value) + `"`)
+		w.mapped(attrStart, attr.Range)
+	}
+
+	w.write(">" + w.newline())
+
+	for _, child := range node.Children {
+		w.node(child, depth+1)
+	}
+
+	if expand {
+		w.content(included, depth+1)
+	}
+
+	w.write(w.indent(depth) + "</" + name + ">")
+	w.mapped(start, node.Range)
+	w.write(w.newline())
+}
+
+// isNamedReturn returns true for the element of a return arrow with a name. The name is
+// an element of its own inside, while a return arrow without a name is an element called "ret".
+func isNamedReturn(doc *Document, node *Node) bool {
+	return node.G2 && !node.IsRoot() && node.BlockRange == nil && len(node.Children) == 1 &&
+		node.Children[0].IsElement() && doc.textIn(node.NameRange) == "->"
+}
+
+// node writes an element, text or comment.
+func (w *xmlWriter) node(node *Node, depth int) {
+	var xml string
+
+	switch {
+	case node.Comment != nil && w.options.OmitComments:
+		return
+	case node.Comment != nil:
+		xml = "<!-- " + xmlComment(xmlEscaper.Replace(*node.Comment)) + " -->"
+	case node.Text != nil && node.Forwarded && !w.options.Minify:
+		xml = xmlEscaper.Replace(*node.Text)
+	case node.Text != nil && w.options.Minify:
+		xml = minifiedText(node, xmlEscaper.Replace(*node.Text))
+	case node.Text != nil:
+		xml = strings.TrimSpace(xmlEscaper.Replace(*node.Text))
+	default:
+		w.element(node, depth)
+
+		return
+	}
+
+	w.write(w.indent(depth))
+	start := w.pos
+	w.write(xml)
+	w.mapped(start, node.Range)
+	w.write(w.newline())
+}
+
+// xmlComment splits each "--" in a comment with a space, as XML does not allow it in comments.
+func xmlComment(comment string) string {
+	for strings.Contains(comment, "--") {
+		comment = strings.ReplaceAll(comment, "--", "- -")
+	}
+
+	return comment
+}
+
+// minifiedText trims the whitespace around text, but keeps a single space on each side that has whitespace
+// and another node next to it, so that words are not joined to the tags around them. In G1, the whitespace
+// after a bracket is not part of the text, so a gap to the node before or after it counts as well.
+func minifiedText(node *Node, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || node.Parent == nil {
+		return trimmed
+	}
+
+	siblings := node.Parent.Children
+
+	for i, sibling := range siblings {
+		if sibling != node {
+			continue
+		}
+
+		if i > 0 && (strings.TrimLeftFunc(text, unicode.IsSpace) != text ||
+			!node.Parent.G2 && siblings[i-1].Range.End != node.Range.Start) {
+			trimmed = " " + trimmed
+		}
+
+		if i < len(siblings)-1 && (strings.TrimRightFunc(text, unicode.IsSpace) != text ||
+			!node.Parent.G2 && siblings[i+1].Range.Start != node.Range.End) {
+			trimmed += " "
+		}
+	}
+
+	return trimmed
+}
+
+// root writes the root element with the given nodes inside, which are the top level of a document or a selection of it.
+// The namespaces are declared here.
+func (w *xmlWriter) root(nodes []*Node) {
+	if w.options.Declaration {
+		encoding := w.options.Encoding
+		if encoding == "" {
+			encoding = "UTF-8"
+		}
+
+		w.write(`<?xml version="1.0" encoding="` + xmlEscaper.Replace(encoding) + `"?>` + w.newline())
+	}
+
+	name := w.options.RootName
+	if name == "" {
+		name = "root"
+	}
+
+	w.write("<" + name)
+
+	prefixes := make([]string, 0, len(w.options.Namespaces))
+	for prefix := range w.options.Namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		attr := "xmlns"
+		if prefix != "" {
+			attr += ":" + prefix
+		}
+
+		w.write(" " + attr + `="` + xmlEscaper.Replace(w.options.Namespaces[prefix]) + `"`)
+	}
+
+	w.write(">" + w.newline())
+
+	for _, node := range nodes {
+		w.node(node, 1)
+	}
+
+	w.write("</" + name + ">" + w.newline())
+}
+
+// indent returns the indentation for the depth of nesting.
+func (w *xmlWriter) indent(depth int) string {
+	if w.options.Minify {
+		return ""
+	}
+
+	if w.options.Indent == "" {
+		return strings.Repeat("    ", depth)
+	}
+
+	return strings.Repeat(w.options.Indent, depth)
+}
+
+// newline returns what ends a line, which is nothing for minified XML.
+func (w *xmlWriter) newline() string {
+	if w.options.Minify {
+		return ""
+	}
+
+	return "\n"
+}
+
+// name returns the name of an element or attribute in XML, replacing the dot after a namespace prefix with a colon.
+func (w *xmlWriter) name(name string) string {
+	if i := strings.Index(name, "."); i > 0 {
+		if _, ok := w.options.Namespaces[name[:i]]; ok {
+			return name[:i] + ":" + name[i+1:]
+		}
+	}
+
+	return name
+}
+
+// writeXML returns the XML for nodes of a document, which are wrapped in the root element, and where in the documents
+// each part of the XML comes from. Includes are resolved with the given function.
+func writeXML(doc *Document, nodes []*Node, options XMLOptions, resolve func(doc *Document, node *Node) (include, *Document, bool)) (string, []SourceMapping) {
+	w := &xmlWriter{
+		expander: expander{expand: options.ExpandIncludes, resolve: resolve, docs: []*Document{doc}},
+		options:  options,
+	}
+	w.root(nodes)
+
+	return w.sb.String(), w.mappings
+}
@@ -0,0 +1,98 @@
+package dyml
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlPlain matches strings that can be written without quotes and are read back as the same string.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_ ./\-]*$`)
+
+// yamlKeywords are read as booleans or null, if they are not quoted.
+var yamlKeywords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "y": true, "n": true, "null": true,
+}
+
+// yamlString returns a string as YAML scalar. Strings that YAML would read as something else are quoted.
+func yamlString(s string) string {
+	if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") && !yamlKeywords[strings.ToLower(s)] {
+		return s
+	}
+
+	return strconv.Quote(s)
+}
+
+// yamlWriter writes objects of jsonBuilder as YAML in block style.
+type yamlWriter struct {
+	sb     strings.Builder
+	indent string
+}
+
+// object writes the keys of an object, each in its own line. The first line starts with first instead of indent,
+// which is how items of lists start.
+func (w *yamlWriter) object(obj *jsonObject, indent, first string) {
+	for i, key := range obj.keys {
+		if i == 0 {
+			w.sb.WriteString(first)
+		} else {
+			w.sb.WriteString(indent)
+		}
+
+		w.sb.WriteString(yamlString(key) + ":")
+		w.value(obj.values[key], indent+w.indent)
+	}
+}
+
+// value writes the value of a key. Strings stay in the line of the key, objects and lists start in the next line.
+func (w *yamlWriter) value(value jsonValue, indent string) {
+	switch value := value.(type) {
+	case string:
+		w.sb.WriteString(" " + yamlString(value) + "\n")
+	case *jsonObject:
+		if len(value.keys) == 0 {
+			w.sb.WriteString(" {}\n")
+
+			return
+		}
+
+		w.sb.WriteString("\n")
+		w.object(value, indent, indent)
+	case []jsonValue:
+		w.sb.WriteString("\n")
+
+		for _, item := range value {
+			switch item := item.(type) {
+			case string:
+				w.sb.WriteString(indent + "- " + yamlString(item) + "\n")
+			case *jsonObject:
+				if len(item.keys) == 0 {
+					w.sb.WriteString(indent + "- {}\n")
+				} else {
+					w.object(item, indent+"  ", indent+"- ")
+				}
+			}
+		}
+	}
+}
+
+// writeYAML returns the YAML for an object. Minified YAML is written as JSON, which YAML reads as well.
+// Indentation must be spaces in YAML, so other indentation is replaced by two spaces.
+func writeYAML(obj *jsonObject, options XMLOptions) string {
+	if options.Minify {
+		return writeJSON(obj, options)
+	}
+
+	if len(obj.keys) == 0 {
+		return "{}\n"
+	}
+
+	w := &yamlWriter{indent: options.Indent}
+	if w.indent == "" || strings.Trim(w.indent, " ") != "" {
+		w.indent = "  "
+	}
+
+	w.object(obj, "", "")
+
+	return w.sb.String()
+}
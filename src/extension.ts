@@ -7,6 +7,45 @@ import { LanguageClientOptions, LanguageClient, ServerOptions, TransportKind } f
 
 let client: LanguageClient;
 
+// xmlPreviews maps the URIs of open XML previews to the DYML documents they were encoded from.
+let xmlPreviews = new Map<string, vscode.Uri>();
+
+// renameScopes are what a rename can change, see the dyml.renameScope setting.
+let renameScopes = [
+	{scope: "workspace", label: "Workspace", description: "All elements or attributes at the same path in all documents"},
+	{scope: "document", label: "Document", description: "All elements or attributes at the same path in this document"},
+	{scope: "node", label: "Only this one", description: "The element or attribute under the cursor"},
+];
+
+// EncodeResult is the response to custom/encode.
+interface EncodeResult {
+	output: string;
+	errors: {range: vscode.Range; message: string}[];
+	success: boolean;
+	version: number;
+}
+
+// PreviewUpdate is the result of custom/preview/subscribe and the parameters of custom/preview/update.
+interface PreviewUpdate {
+	uri?: string;
+	xml: string;
+	errors: {range: vscode.Range; message: string}[];
+	success: boolean;
+	version: number;
+}
+
+// DecodeXMLResult is the response to custom/decodeXML.
+interface DecodeXMLResult {
+	dyml: string;
+	errors: {range: vscode.Range; message: string}[];
+	success: boolean;
+}
+
+// DecodeResult is the response to custom/decode.
+interface DecodeResult extends DecodeXMLResult {
+	format: string;
+}
+
 export function activate(context: vscode.ExtensionContext) {
 
 	// Select correct language server binary for this platform.
@@ -27,6 +66,30 @@ export function activate(context: vscode.ExtensionContext) {
 	};
 	let clientOptions: LanguageClientOptions = {
 		documentSelector: [{scheme: "file", language: "dyml"}],
+		initializationOptions: vscode.workspace.getConfiguration("dyml"),
+		synchronize: {
+			configurationSection: "dyml",
+			// Files that are not open are checked as well, schemas and Go types affect other documents.
+			fileEvents: vscode.workspace.createFileSystemWatcher("**/*.{dyml,go}"),
+		},
+		middleware: {
+			// Ask what to rename, the dyml.renameScope setting is offered first.
+			provideRenameEdits: async (document, position, newName, token) => {
+				let setting = vscode.workspace.getConfiguration("dyml").get<string>("renameScope", "workspace");
+				let scopes = renameScopes.slice().sort((a, b) => Number(b.scope === setting) - Number(a.scope === setting));
+				let picked = await vscode.window.showQuickPick(scopes, {placeHolder: `Rename to '${newName}' in`});
+				if (!picked) {
+					return undefined;
+				}
+				let edit = await client.sendRequest<any>("textDocument/rename", {
+					textDocument: {uri: document.uri.toString()},
+					position: client.code2ProtocolConverter.asPosition(position),
+					newName: newName,
+					scope: picked.scope
+				}, token);
+				return edit ? client.protocol2CodeConverter.asWorkspaceEdit(edit) : undefined;
+			},
+		},
 	};
 	client = new LanguageClient(
 		"dyml-language-server",
@@ -36,22 +99,161 @@ export function activate(context: vscode.ExtensionContext) {
 	);
 	client.start();
 
-	// Request an XML preview from the language server and show that result in a new editor.
-	context.subscriptions.push(vscode.commands.registerCommand("dyml.encodeXML", () => {
-		let doc = "file://" + vscode.window.activeTextEditor?.document.uri.fsPath;
-		if (doc) {
-			client.sendRequest("custom/encodeXML", doc).then((resp) => {
-				vscode.workspace.openTextDocument({
-					content: String(resp),
-					language: "xml"
-				}).then((document) => {
-					vscode.window.showTextDocument(document);
-				});
+	// Replace the content of XML previews, when the language server encoded their document again after a change.
+	client.onReady().then(() => {
+		client.onNotification("custom/preview/update", (update: PreviewUpdate) => {
+			for (let document of vscode.workspace.textDocuments) {
+				if (xmlPreviews.get(document.uri.toString())?.toString() !== update.uri) {
+					continue;
+				}
+				let edit = new vscode.WorkspaceEdit();
+				edit.replace(document.uri, new vscode.Range(0, 0, document.lineCount, 0), update.xml);
+				vscode.workspace.applyEdit(edit);
+			}
+			if (!update.success && update.errors.length > 0) {
+				let error = update.errors[0];
+				vscode.window.setStatusBarMessage(`The XML is incomplete, line ${error.range.start.line + 1}: ${error.message}`, 5000);
+			}
+		});
+	});
+
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.encodeXML", previewXML));
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.encodeSelectionXML", () => encode("xml", true)));
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.encodeJSON", () => encode("json", false)));
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.encodeYAML", () => encode("yaml", false)));
+
+	// Jump from the cursor in an XML preview to the DYML it was encoded from.
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.revealSource", async () => {
+		let editor = vscode.window.activeTextEditor;
+		let source = editor && xmlPreviews.get(editor.document.uri.toString());
+		if (!editor || !source) {
+			vscode.window.showInformationMessage("Go to DYML Source only works in XML previews of DYML documents.");
+			return;
+		}
+		let location = await client.sendRequest<any>("custom/xmlToSource", {
+			textDocument: {uri: source.toString()},
+			position: editor.selection.active
+		});
+		if (location) {
+			let target = client.protocol2CodeConverter.asLocation(location);
+			let document = await vscode.workspace.openTextDocument(target.uri);
+			vscode.window.showTextDocument(document, {selection: new vscode.Range(target.range.start, target.range.start)});
+		}
+	}));
+
+	// Scroll XML previews along with the DYML they were encoded from.
+	context.subscriptions.push(vscode.window.onDidChangeTextEditorVisibleRanges(async (event) => {
+		let source = event.textEditor.document;
+		if (source.languageId !== "dyml" || event.visibleRanges.length === 0) {
+			return;
+		}
+		let line = source.lineAt(event.visibleRanges[0].start.line);
+		for (let editor of vscode.window.visibleTextEditors) {
+			if (xmlPreviews.get(editor.document.uri.toString())?.toString() !== source.uri.toString()) {
+				continue;
+			}
+			let range = await client.sendRequest<any>("custom/sourceToXML", {
+				textDocument: {uri: source.uri.toString()},
+				position: {line: line.lineNumber, character: line.firstNonWhitespaceCharacterIndex}
 			});
+			if (range) {
+				editor.revealRange(client.protocol2CodeConverter.asRange(range), vscode.TextEditorRevealType.AtTop);
+			}
+		}
+	}));
+	// Stop updating the XML of a document, once its last preview was closed.
+	context.subscriptions.push(vscode.workspace.onDidCloseTextDocument((document) => {
+		let source = xmlPreviews.get(document.uri.toString())?.toString();
+		xmlPreviews.delete(document.uri.toString());
+		if (source && ![...xmlPreviews.values()].some((uri) => uri.toString() === source)) {
+			client.sendRequest("custom/preview/unsubscribe", {textDocument: {uri: source}});
+		}
+	}));
+
+	// Convert the XML in the active editor to DYML in the chosen grammar and show it in a new editor.
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.decodeXML", async () => {
+		let doc = vscode.window.activeTextEditor?.document;
+		if (!doc) {
+			return;
+		}
+		let grammar = await vscode.window.showQuickPick(["g1", "g2"], {placeHolder: "Grammar of the DYML"});
+		if (!grammar) {
+			return;
+		}
+		let resp = await client.sendRequest<DecodeXMLResult>("custom/decodeXML", {xml: doc.getText(), grammar: grammar});
+		if (!resp.success && resp.errors.length > 0) {
+			let error = resp.errors[0];
+			vscode.window.showWarningMessage(`The DYML is incomplete, line ${error.range.start.line + 1}: ${error.message}`);
+		} else if (resp.errors.length > 0) {
+			let warning = resp.errors[0];
+			vscode.window.showWarningMessage(`${resp.errors.length} comment(s) left out, line ${warning.range.start.line + 1}: ${warning.message}`);
+		}
+		let document = await vscode.workspace.openTextDocument({content: resp.dyml, language: "dyml"});
+		vscode.window.showTextDocument(document);
+	}));
+
+	// Convert XML, JSON or YAML from the clipboard to DYML and insert it at the cursor, indented like the current line.
+	context.subscriptions.push(vscode.commands.registerCommand("dyml.pasteAsDYML", async () => {
+		let editor = vscode.window.activeTextEditor;
+		if (!editor) {
+			return;
 		}
+		let resp = await client.sendRequest<DecodeResult>("custom/decode", {text: await vscode.env.clipboard.readText()});
+		if (!resp.success) {
+			let error = resp.errors[0];
+			vscode.window.showErrorMessage(`Cannot paste ${resp.format.toUpperCase()} as DYML, line ${error.range.start.line + 1}: ${error.message}`);
+			return;
+		}
+		let line = editor.document.lineAt(editor.selection.start.line);
+		let indent = line.text.substring(0, line.firstNonWhitespaceCharacterIndex);
+		let dyml = resp.dyml.trimEnd().split("\n").join("\n" + indent);
+		let selection = editor.selection;
+		editor.edit((edit) => edit.replace(selection, dyml));
 	}));
 }
 
+// Show an XML preview of the active document, which the language server keeps up to date while it is edited.
+async function previewXML() {
+	let editor = vscode.window.activeTextEditor;
+	if (!editor) {
+		return;
+	}
+	let source = editor.document.uri;
+	let resp = await client.sendRequest<PreviewUpdate>("custom/preview/subscribe", {textDocument: {uri: source.toString()}});
+	if (!resp.success && resp.errors.length > 0) {
+		let error = resp.errors[0];
+		vscode.window.showWarningMessage(`The XML is incomplete, line ${error.range.start.line + 1}: ${error.message}`);
+	}
+	let document = await vscode.workspace.openTextDocument({content: resp.xml, language: "xml"});
+	xmlPreviews.set(document.uri.toString(), source);
+	vscode.window.showTextDocument(document);
+}
+
+// Request an XML, JSON or YAML preview from the language server and show that result in a new editor.
+// With a selection only the selected elements are encoded, or the element around the cursor.
+// Documents with syntax errors are encoded as far as possible, the first error is shown along with it.
+// The result is not a live preview, it is not updated and does not map back to the DYML.
+function encode(format: string, selection: boolean) {
+	let editor = vscode.window.activeTextEditor;
+	if (editor) {
+		let doc = editor.document;
+		client.sendRequest<EncodeResult>("custom/encode", {
+			textDocument: {uri: doc.uri.toString(), version: doc.version},
+			format: format,
+			range: selection ? editor.selection : undefined
+		}).then((resp) => {
+			if (!resp.success && resp.errors.length > 0) {
+				let error = resp.errors[0];
+				vscode.window.showWarningMessage(`The ${format.toUpperCase()} is incomplete, line ${error.range.start.line + 1}: ${error.message}`);
+			}
+			vscode.workspace.openTextDocument({
+				content: resp.output,
+				language: format
+			}).then((document) => vscode.window.showTextDocument(document));
+		});
+	}
+}
+
 // Shut down language server and close preview panels when extension is deactivated
 export function deactivate(): Thenable<void> | undefined {
 	if (!client) {